@@ -0,0 +1,91 @@
+package cache
+
+// getMulti gets each of keys from c.
+func getMulti(c Cache, keys []string) (values [][]byte, found []bool) {
+	values = make([][]byte, len(keys))
+	found = make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = c.Get(key)
+	}
+	return values, found
+}
+
+// setMulti sets each of keys in c to the value at the same index. If evict is
+// non-nil it is called first until the bytes the batch adds fit, so that the
+// Sets don't each have to evict.
+func setMulti(c Cache, keys []string, values [][]byte, evict func()) []bool {
+	if evict != nil {
+		// Only the last value of a key and values that fit are added
+		last := map[string]int{}
+		for i := 0; i < len(keys) && i < len(values); i++ {
+			last[keys[i]] = i
+		}
+		added := 0
+		for key, i := range last {
+			size := len(key) + len(values[i])
+			if size > c.MaxStorage() {
+				continue
+			}
+			added += size
+			if existing, ok := peek(c, key); ok {
+				added -= len(key) + len(existing)
+			}
+		}
+		evictFor(c, added, evict)
+	}
+
+	ok := make([]bool, len(keys))
+	for i := 0; i < len(keys) && i < len(values); i++ {
+		ok[i] = c.Set(keys[i], values[i])
+	}
+	return ok
+}
+
+// removeMulti removes each of keys from c.
+func removeMulti(c Cache, keys []string) (values [][]byte, found []bool) {
+	values = make([][]byte, len(keys))
+	found = make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = c.Remove(key)
+	}
+	return values, found
+}
+
+// batchGet is GetMulti on c if it is a Batcher, and Get of each key
+// otherwise.
+func batchGet(c Cache, keys []string) (values [][]byte, found []bool) {
+	if batcher, ok := c.(Batcher); ok {
+		return batcher.GetMulti(keys)
+	}
+	return getMulti(c, keys)
+}
+
+// batchSet is SetMulti on c if it is a Batcher, and Set of each key
+// otherwise.
+func batchSet(c Cache, keys []string, values [][]byte) []bool {
+	if batcher, ok := c.(Batcher); ok {
+		return batcher.SetMulti(keys, values)
+	}
+	return setMulti(c, keys, values, nil)
+}
+
+// batchRemove is RemoveMulti on c if it is a Batcher, and Remove of each key
+// otherwise.
+func batchRemove(c Cache, keys []string) (values [][]byte, found []bool) {
+	if batcher, ok := c.(Batcher); ok {
+		return batcher.RemoveMulti(keys)
+	}
+	return removeMulti(c, keys)
+}
+
+// evictFor calls evict until size more bytes fit in c, or until it stops
+// evicting because every binding left is pinned.
+func evictFor(c Cache, size int, evict func()) {
+	for c.RemainingStorage() < size {
+		n := c.Len()
+		evict()
+		if c.Len() == n {
+			return
+		}
+	}
+}
@@ -0,0 +1,133 @@
+/******************************************************************************
+ * batch_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    A testing suite for GetMulti, SetMulti and RemoveMulti
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// Batch operations return a result for each key, in order, for every policy
+// and through every wrapper.
+func TestBatch(t *testing.T) {
+	for _, policy := range experimentPolicies {
+		recording, _ := NewRecordingCache(policy.factory(100), io.Discard, RecordingOptions{})
+		for _, cache := range []Batcher{
+			policy.factory(100).(Batcher),
+			NewSyncCache(policy.factory(100)),
+			NewSizingCache(policy.factory(100).(Evicter)),
+			NewVersionedCache(policy.factory(100).(Evicter)),
+			NewTaggedCache(policy.factory(100).(Evicter)),
+			recording,
+			NewStoredCache(policy.factory(100).(FrontCache), newMapStore(), WriteThrough, 0),
+			NewStoredCache(policy.factory(100).(FrontCache), newMapStore(), WriteBack, 0),
+		} {
+			keys := []string{"a", "b", "large", "c"}
+			values := [][]byte{[]byte("1"), []byte("2"), make([]byte, 100), []byte("3")}
+			if ok := cache.SetMulti(keys, values); fmt.Sprint(ok) != "[true true false true]" {
+				t.Errorf("%s: SetMulti returned %v", policy.name, ok)
+				t.FailNow()
+			}
+
+			values, found := cache.GetMulti([]string{"c", "missing", "a"})
+			if fmt.Sprint(found) != "[true false true]" || string(values[0]) != "3" || string(values[2]) != "1" {
+				t.Errorf("%s: GetMulti returned %q and %v", policy.name, values, found)
+				t.FailNow()
+			}
+
+			values, found = cache.RemoveMulti([]string{"b", "b", "large"})
+			if fmt.Sprint(found) != "[true false false]" || string(values[0]) != "2" || cache.Len() != 2 {
+				t.Errorf("%s: RemoveMulti returned %q and %v, leaving %d bindings", policy.name, values, found, cache.Len())
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Wrappers keep their own state for batches as they do for single operations.
+func TestWrapperBatch(t *testing.T) {
+	keys := []string{"a", "b"}
+	values := [][]byte{[]byte("1"), []byte("2")}
+
+	sizing := NewSizingCache(NewLru(100))
+	sizing.SetMulti(keys, values)
+	sizing.GetMulti([]string{"a", "missing"})
+	if sizing.requests != 2 || sizing.hits != 1 || sizing.resident.len() != 2 {
+		t.Errorf("SizingCache counted %d requests and %d hits with %d residents, expected 2, 1 and 2",
+			sizing.requests, sizing.hits, sizing.resident.len())
+		t.FailNow()
+	}
+
+	versioned := NewVersionedCache(NewLru(100))
+	versioned.SetMulti(keys, values)
+	if _, cas, ok := versioned.Gets("b"); !ok || cas == 0 || versioned.Add("a", values[0]) {
+		t.Errorf("SetMulti should give each binding a CAS token")
+		t.FailNow()
+	}
+
+	tagged := NewTaggedCache(NewLru(100))
+	tagged.SetMulti(keys, values)
+	if removed := tagged.RemovePrefix(""); removed != 2 {
+		t.Errorf("RemovePrefix removed %d bindings set in a batch, expected 2", removed)
+		t.FailNow()
+	}
+
+	store := newMapStore()
+	stored := NewStoredCache(NewLru(100), store, WriteBack, 0)
+	stored.SetMulti(keys, values)
+	if stored.Dirty() != 2 || len(store.values) != 0 {
+		t.Errorf("WriteBack SetMulti left %d dirty and wrote %d, expected 2 and none", stored.Dirty(), len(store.values))
+		t.FailNow()
+	}
+	stored = NewStoredCache(NewLru(100), store, WriteThrough, 0)
+	stored.SetMulti(keys, values)
+	if stored.Dirty() != 0 || len(store.values) != 2 {
+		t.Errorf("WriteThrough SetMulti left %d dirty and wrote %d, expected none and 2", stored.Dirty(), len(store.values))
+		t.FailNow()
+	}
+	stored.RemoveMulti(keys)
+	if stored.Len() != 0 || len(store.values) != 0 {
+		t.Errorf("RemoveMulti left %d cached and %d stored", stored.Len(), len(store.values))
+		t.FailNow()
+	}
+}
+
+// SetMulti evicts for the whole batch before adding any of it, so an LFU
+// evicts its old bindings rather than the new ones it just added.
+func TestSetMultiEvictsOnce(t *testing.T) {
+	lfu := NewLfu(100)
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("old%d", i)
+		lfu.Set(key, []byte("value"))
+		lfu.Get(key)
+	}
+
+	keys := []string{}
+	values := [][]byte{}
+	for i := 0; i < 5; i++ {
+		keys = append(keys, fmt.Sprintf("new%d", i))
+		values = append(values, []byte("value"))
+	}
+	lfu.SetMulti(keys, values)
+	for _, key := range keys {
+		if !lfu.Contains(key) {
+			t.Errorf("%s was evicted by the batch it was set in", key)
+			t.FailNow()
+		}
+	}
+	if lfu.Len() != 11 {
+		t.Errorf("Expected 11 bindings after SetMulti, found %d", lfu.Len())
+		t.FailNow()
+	}
+}
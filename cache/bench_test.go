@@ -0,0 +1,207 @@
+/******************************************************************************
+ * bench_test.go
+ * Author:
+ * Usage:    `go test -run XXX -bench . -count 10 > new.txt`, then
+ *           `benchstat old.txt new.txt` to compare with an earlier commit
+ * Description:
+ *    Benchmarks of every policy under common workloads. Besides ns/op and
+ *    allocs/op each benchmark reports the median and 99th percentile
+ *    latency of a sample of its operations.
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+/******************************************************************************/
+/*                                Constants                                   */
+/******************************************************************************/
+
+// Cache sizes to benchmark, in bytes. Bindings take 16 bytes.
+var benchSizes = []int{1 << 12, 1 << 18}
+
+// Number of distinct keys benchmarks draw from, more than any cache holds.
+const benchKeys = 1 << 17
+
+// Every latencyEvery-th operation is timed for the latency percentiles.
+const latencyEvery = 16
+
+var benchKeyNames = makeBenchKeys()
+
+func makeBenchKeys() []string {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08d", i)
+	}
+	return keys
+}
+
+/******************************************************************************/
+/*                                Benchmarks                                  */
+/******************************************************************************/
+
+func BenchmarkGetHit(b *testing.B) {
+	benchPolicies(b, func(b *testing.B, cache Cache, entries int) {
+		fill(cache, entries)
+		runOps(b, func(i int) {
+			cache.Get(benchKeyNames[i%entries])
+		})
+	})
+}
+
+func BenchmarkGetMiss(b *testing.B) {
+	benchPolicies(b, func(b *testing.B, cache Cache, entries int) {
+		fill(cache, entries)
+		runOps(b, func(i int) {
+			cache.Get(benchKeyNames[entries+i%(benchKeys-entries)])
+		})
+	})
+}
+
+func BenchmarkSetNew(b *testing.B) {
+	benchPolicies(b, func(b *testing.B, cache Cache, entries int) {
+		fill(cache, entries)
+		runOps(b, func(i int) {
+			key := benchKeyNames[(entries+i)%benchKeys]
+			cache.Set(key, []byte(key))
+		})
+	})
+}
+
+func BenchmarkSetUpdate(b *testing.B) {
+	benchPolicies(b, func(b *testing.B, cache Cache, entries int) {
+		fill(cache, entries)
+		runOps(b, func(i int) {
+			key := benchKeyNames[i%entries]
+			cache.Set(key, []byte(key))
+		})
+	})
+}
+
+// A read-through workload: keys are drawn from a Zipf distribution and set
+// on a miss.
+func BenchmarkZipf(b *testing.B) {
+	benchPolicies(b, func(b *testing.B, cache Cache, entries int) {
+		keys := zipfKeys(1)
+		runOps(b, func(i int) {
+			key := keys[i%len(keys)]
+			if _, ok := cache.Get(key); !ok {
+				cache.Set(key, []byte(key))
+			}
+		})
+	})
+}
+
+// The Zipf workload from several goroutines through a SyncCache.
+func BenchmarkZipfParallel(b *testing.B) {
+	benchPolicies(b, func(b *testing.B, cache Cache, entries int) {
+		sc := NewSyncCache(cache)
+		sequences := make(chan []string, runtime.GOMAXPROCS(0))
+		for seed := int64(1); seed <= int64(cap(sequences)); seed++ {
+			sequences <- zipfKeys(seed)
+		}
+		var mu sync.Mutex
+		latencies := []time.Duration{}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			keys := <-sequences
+			samples := []time.Duration{}
+			for i := 0; pb.Next(); i++ {
+				timeOp(&samples, i, func(i int) {
+					key := keys[i%len(keys)]
+					if _, ok := sc.Get(key); !ok {
+						sc.Set(key, []byte(key))
+					}
+				})
+			}
+			mu.Lock()
+			latencies = append(latencies, samples...)
+			mu.Unlock()
+		})
+		b.StopTimer()
+		reportLatencies(b, latencies)
+	})
+}
+
+/******************************************************************************/
+/*                                 Helpers                                    */
+/******************************************************************************/
+
+// benchPolicies runs bench as a sub-benchmark for every policy at every size,
+// with a new cache and the number of bindings it holds when full.
+func benchPolicies(b *testing.B, bench func(b *testing.B, cache Cache, entries int)) {
+	for _, policy := range experimentPolicies {
+		for _, size := range benchSizes {
+			b.Run(fmt.Sprintf("%s/size=%d", policy.name, size), func(b *testing.B) {
+				bench(b, policy.factory(size), size/16)
+			})
+		}
+	}
+}
+
+// fill sets the first entries keys.
+func fill(cache Cache, entries int) {
+	for _, key := range benchKeyNames[:entries] {
+		cache.Set(key, []byte(key))
+	}
+}
+
+// zipfKeys returns a sequence of keys drawn from a Zipf distribution.
+func zipfKeys(seed int64) []string {
+	zipf := rand.NewZipf(rand.New(rand.NewSource(seed)), 1.1, 1, benchKeys-1)
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = benchKeyNames[zipf.Uint64()]
+	}
+	return keys
+}
+
+// runOps calls op b.N times, reporting allocations and the p50 and p99
+// latency of every latencyEvery-th call.
+func runOps(b *testing.B, op func(i int)) {
+	latencies := make([]time.Duration, 0, b.N/latencyEvery+1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		timeOp(&latencies, i, op)
+	}
+	b.StopTimer()
+	reportLatencies(b, latencies)
+}
+
+// timeOp calls op, adding how long it took to latencies if i is a multiple
+// of latencyEvery.
+func timeOp(latencies *[]time.Duration, i int, op func(i int)) {
+	if i%latencyEvery != 0 {
+		op(i)
+		return
+	}
+	start := time.Now()
+	op(i)
+	*latencies = append(*latencies, time.Since(start))
+}
+
+// reportLatencies reports the p50 and p99 of latencies as metrics of b.
+func reportLatencies(b *testing.B, latencies []time.Duration) {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(percentile(latencies, 0.5).Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(percentile(latencies, 0.99).Nanoseconds()), "p99-ns")
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	return latencies[int(p*float64(len(latencies)-1))]
+}
@@ -0,0 +1,499 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// A BudgetUsage describes how a cache in a MemoryBudget uses its room.
+type BudgetUsage struct {
+	Capacity int // bytes the budget gives the cache
+	Used     int // bytes its bindings take
+	Weight   float64
+
+	// Min is how many bytes the cache is guaranteed to keep, and Max the
+	// most it can be given
+	Min int
+	Max int
+
+	// MarginalHitRate is the fraction of recent Gets that missed on keys
+	// the cache evicted recently, about how many more Gets would hit if it
+	// had budgetGhostFraction more of the budget's room.
+	MarginalHitRate float64
+}
+
+// A VictimFunc chooses which of the caches in a MemoryBudget gives up room
+// when another one needs it, returning its index in candidates. candidates
+// all hold at least one binding; the cache that needs room is among them if
+// it could make room by evicting its own bindings instead.
+type VictimFunc func(candidates []BudgetUsage) int
+
+// LargestVictim takes room from the cache using the most bytes.
+func LargestVictim(candidates []BudgetUsage) int {
+	victim := 0
+	for i, usage := range candidates {
+		if usage.Used > candidates[victim].Used {
+			victim = i
+		}
+	}
+	return victim
+}
+
+// MarginalVictim takes room from the cache that would lose the fewest hits
+// for it, judged by how often its recently evicted keys are asked for again.
+// Ties go to the cache using the most bytes.
+func MarginalVictim(candidates []BudgetUsage) int {
+	victim := 0
+	for i, usage := range candidates {
+		best := candidates[victim]
+		if usage.MarginalHitRate < best.MarginalHitRate ||
+			(usage.MarginalHitRate == best.MarginalHitRate && usage.Used > best.Used) {
+			victim = i
+		}
+	}
+	return victim
+}
+
+// OverMinVictim takes room from the cache using the most bytes beyond the
+// minimum it is guaranteed. Ties go to the cache using the most bytes.
+func OverMinVictim(candidates []BudgetUsage) int {
+	victim := 0
+	for i, usage := range candidates {
+		best := candidates[victim]
+		over, bestOver := usage.Used-usage.Min, best.Used-best.Min
+		if over > bestOver || (over == bestOver && usage.Used > best.Used) {
+			victim = i
+		}
+	}
+	return victim
+}
+
+// FairShareVictim takes room from the cache using the most bytes for its
+// weight, so that caches under contention end up with room in proportion to
+// their weights.
+func FairShareVictim(candidates []BudgetUsage) int {
+	victim := 0
+	for i, usage := range candidates {
+		best := candidates[victim]
+		if float64(usage.Used)/usage.Weight > float64(best.Used)/best.Weight {
+			victim = i
+		}
+	}
+	return victim
+}
+
+// budgetGhostFraction is the fraction of a MemoryBudget's limit each of its
+// caches remembers evicted keys for, to estimate its marginal hit rate.
+const budgetGhostFraction = 0.1
+
+// budgetDecayEvery is the number of Gets on a cache after which the counts
+// behind its marginal hit rate are halved, so it follows recent traffic.
+const budgetDecayEvery = 1 << 14
+
+// A BudgetMember is a cache that can be registered with a MemoryBudget, which
+// resizes it to move room between its members. Every policy in this package
+// is a BudgetMember.
+type BudgetMember interface {
+	Evicter
+	Resize(limit int)
+	Peek(key string) (value []byte, ok bool)
+}
+
+// A MemoryBudget keeps the total capacity of several caches under a global
+// limit. Each cache registered with it is wrapped in a BudgetedCache, whose
+// capacity grows as it needs room: from room no cache has, then from room
+// other caches have but don't use, and then from a victim chosen by the
+// budget's VictimFunc, which is shrunk with Resize and so evicts according to
+// its own policy. Caches can have a minimum they are never shrunk below and
+// a maximum they never grow beyond, as with the tenants of a
+// NamespacedCache.
+//
+// A MemoryBudget and its BudgetedCaches are safe for concurrent use; every
+// operation on any of them is serialized with a single mutex.
+type MemoryBudget struct {
+	mu       sync.Mutex
+	limit    int
+	victim   VictimFunc
+	members  []*BudgetedCache
+	reserved int // sum of the members' minimums
+}
+
+// NewMemoryBudget returns a MemoryBudget that shares limit bytes between the
+// caches registered with it, taking room from the one victim chooses.
+func NewMemoryBudget(limit int, victim VictimFunc) *MemoryBudget {
+	budget := new(MemoryBudget)
+	budget.limit = limit
+	budget.victim = victim
+	budget.members = []*BudgetedCache{}
+	budget.reserved = 0
+	return budget
+}
+
+// Limit returns the number of bytes shared by the caches in the budget.
+func (budget *MemoryBudget) Limit() int {
+	return budget.limit
+}
+
+// Used returns the number of bytes bindings take across the caches in the
+// budget.
+func (budget *MemoryBudget) Used() int {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	used := 0
+	for _, bc := range budget.members {
+		used += bc.used()
+	}
+	return used
+}
+
+// Register adds cache to the budget with the given weight, which only
+// FairShareVictim uses; weights that are not positive count as 1. cache
+// keeps its capacity if the budget has room for it, and is shrunk to the
+// room there is otherwise. cache must only be used through the returned
+// BudgetedCache.
+func (budget *MemoryBudget) Register(cache BudgetMember, weight float64) *BudgetedCache {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	return budget.register(cache, weight, 0, budget.limit)
+}
+
+// register adds cache to the budget, guaranteed min bytes and given at most
+// max. The minimums of all members must fit in the budget. The caller must
+// hold budget.mu.
+func (budget *MemoryBudget) register(cache BudgetMember, weight float64, min int, max int) *BudgetedCache {
+	if weight <= 0 {
+		weight = 1
+	}
+	bc := &BudgetedCache{
+		budget:    budget,
+		cache:     cache,
+		weight:    weight,
+		min:       min,
+		max:       max,
+		ghosts:    list.New(),
+		ghostKeys: map[uint64]*list.Element{},
+	}
+	budget.reserved += min
+	if room := budget.free(); bc.capacity() > room || bc.capacity() > bc.limit() {
+		if bc.limit() < room {
+			room = bc.limit()
+		}
+		bc.cache.Resize(room)
+	}
+	cache.OnEvict(bc.evicted)
+	budget.members = append(budget.members, bc)
+	return bc
+}
+
+// free returns the number of bytes not given to any cache.
+func (budget *MemoryBudget) free() int {
+	free := budget.limit
+	for _, bc := range budget.members {
+		free -= bc.capacity()
+	}
+	return free
+}
+
+// reclaim shrinks every cache other than keep to the bytes it uses, and
+// returns whether that freed any room.
+func (budget *MemoryBudget) reclaim(keep *BudgetedCache) bool {
+	freed := false
+	for _, bc := range budget.members {
+		if bc != keep && bc.capacity() > bc.used() {
+			bc.cache.Resize(bc.used())
+			freed = true
+		}
+	}
+	return freed
+}
+
+// makeRoom grows the capacity of bc so that it can add added bytes to what it
+// uses without evicting, for a binding of size bytes, unless that would take
+// it beyond its maximum or the victim chosen is bc itself and the binding
+// fits in its capacity. Caches that can't shrink because their bindings are
+// pinned stop being victims.
+func (budget *MemoryBudget) makeRoom(bc *BudgetedCache, added int, size int) {
+	wanted := bc.used() + added
+	if wanted > bc.limit() {
+		wanted = bc.limit()
+	}
+	need := wanted - bc.capacity()
+	stuck := map[*BudgetedCache]bool{}
+	for need > 0 {
+		if free := budget.free(); free > 0 {
+			grant := need
+			if free < grant {
+				grant = free
+			}
+			bc.cache.Resize(bc.capacity() + grant)
+			need -= grant
+			continue
+		}
+		if budget.reclaim(bc) {
+			continue
+		}
+
+		candidates := []*BudgetedCache{}
+		usages := []BudgetUsage{}
+		for _, other := range budget.members {
+			// bc can evict its own bindings if the new one fits, and other
+			// caches can give up what they have beyond their minimum
+			own := other == bc && bc.used() > 0 && bc.capacity() >= size
+			spare := other != bc && other.used() > other.min && !stuck[other]
+			if own || spare {
+				candidates = append(candidates, other)
+				usages = append(usages, other.usage())
+			}
+		}
+		if len(candidates) == 0 {
+			return
+		}
+		victim := candidates[budget.victim(usages)]
+		if victim == bc {
+			return
+		}
+		shrink := need
+		if victim.used()-victim.min < shrink {
+			shrink = victim.used() - victim.min
+		}
+		capacity := victim.capacity()
+		victim.cache.Resize(capacity - shrink)
+		if victim.capacity() >= capacity {
+			stuck[victim] = true
+		}
+	}
+}
+
+// A BudgetedCache is a cache registered with a MemoryBudget. Its MaxStorage
+// is the most room it can take from the other caches in the budget: its
+// maximum, or what their minimums leave of the budget if that is less.
+type BudgetedCache struct {
+	budget *MemoryBudget
+	cache  BudgetMember
+	weight float64
+	min    int
+	max    int
+
+	// Keys evicted recently, up to budgetGhostFraction of the budget, and
+	// how many Gets asked for one of them
+	ghosts    *list.List // front is the most recently evicted
+	ghostKeys map[uint64]*list.Element
+	ghostSize int
+	requests  float64
+	ghostHits float64
+}
+
+// Usage returns how this cache currently uses its room in the budget.
+func (bc *BudgetedCache) Usage() BudgetUsage {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+	return bc.usage()
+}
+
+func (bc *BudgetedCache) usage() BudgetUsage {
+	usage := BudgetUsage{
+		Capacity: bc.capacity(),
+		Used:     bc.used(),
+		Weight:   bc.weight,
+		Min:      bc.min,
+		Max:      bc.max,
+	}
+	if bc.requests > 0 {
+		usage.MarginalHitRate = bc.ghostHits / bc.requests
+	}
+	return usage
+}
+
+// limit returns the most bytes this cache can be given.
+func (bc *BudgetedCache) limit() int {
+	limit := bc.budget.limit - (bc.budget.reserved - bc.min)
+	if bc.max < limit {
+		limit = bc.max
+	}
+	return limit
+}
+
+// capacity returns the number of bytes the budget gives this cache.
+func (bc *BudgetedCache) capacity() int {
+	return bc.cache.MaxStorage()
+}
+
+// used returns the number of bytes this cache's bindings take.
+func (bc *BudgetedCache) used() int {
+	return bc.cache.MaxStorage() - bc.cache.RemainingStorage()
+}
+
+// evicted remembers an evicted key, forgetting the oldest ones beyond
+// budgetGhostFraction of the budget.
+func (bc *BudgetedCache) evicted(key string, value []byte) {
+	hash := hashKey(key)
+	bc.forgetGhost(hash)
+	entry := &sizingEntry{hash, len(key) + len(value)}
+	bc.ghostKeys[hash] = bc.ghosts.PushFront(entry)
+	bc.ghostSize += entry.size
+	for bc.ghostSize > int(budgetGhostFraction*float64(bc.budget.limit)) {
+		bc.forgetGhost(bc.ghosts.Back().Value.(*sizingEntry).hash)
+	}
+}
+
+// forgetGhost stops remembering hash as an evicted key, and returns whether
+// it was one.
+func (bc *BudgetedCache) forgetGhost(hash uint64) bool {
+	el := bc.ghostKeys[hash]
+	if el == nil {
+		return false
+	}
+	bc.ghostSize -= bc.ghosts.Remove(el).(*sizingEntry).size
+	delete(bc.ghostKeys, hash)
+	return true
+}
+
+// MaxStorage returns the most this cache can store, taking room from the
+// other caches in the budget
+func (bc *BudgetedCache) MaxStorage() int {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+	return bc.limit()
+}
+
+// RemainingStorage returns the number of bytes this cache could still store,
+// taking room from the other caches in the budget if it has to
+func (bc *BudgetedCache) RemainingStorage() int {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+	return bc.limit() - bc.used()
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (bc *BudgetedCache) Get(key string) (value []byte, ok bool) {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+	return bc.get(key)
+}
+
+// get is Get for a caller holding the budget's lock.
+func (bc *BudgetedCache) get(key string) (value []byte, ok bool) {
+	value, ok = bc.cache.Get(key)
+	bc.requests++
+	if !ok && bc.forgetGhost(hashKey(key)) {
+		bc.ghostHits++
+	}
+	if bc.requests >= budgetDecayEvery {
+		bc.requests /= 2
+		bc.ghostHits /= 2
+	}
+	return value, ok
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (bc *BudgetedCache) Remove(key string) (value []byte, ok bool) {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+	return bc.cache.Remove(key)
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// from this or other caches in the budget to make room. Returns true if the
+// binding was added successfully, else false.
+func (bc *BudgetedCache) Set(key string, value []byte) bool {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+
+	size := len(key) + len(value)
+	if size > bc.limit() {
+		return false
+	}
+	added := size
+	if existing, ok := bc.cache.Peek(key); ok {
+		added -= len(key) + len(existing)
+	}
+	bc.budget.makeRoom(bc, added, size)
+	bc.forgetGhost(hashKey(key))
+	return bc.cache.Set(key, value)
+}
+
+// GetMulti gets each of keys while holding the budget's lock once, returning
+// the value of each and whether it was found.
+func (bc *BudgetedCache) GetMulti(keys []string) (values [][]byte, found []bool) {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+
+	values = make([][]byte, len(keys))
+	found = make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = bc.get(key)
+	}
+	return values, found
+}
+
+// SetMulti sets each of keys to the value at the same index while holding the
+// budget's lock once, making room in the budget for the whole batch before
+// adding any of it. Returns whether each binding was added.
+func (bc *BudgetedCache) SetMulti(keys []string, values [][]byte) []bool {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+
+	// Only the last value of a key and values that fit are added
+	last := map[string]int{}
+	for i := 0; i < len(keys) && i < len(values); i++ {
+		last[keys[i]] = i
+	}
+	added, largest := 0, 0
+	for key, i := range last {
+		size := len(key) + len(values[i])
+		if size > bc.limit() {
+			continue
+		}
+		added += size
+		if existing, ok := bc.cache.Peek(key); ok {
+			added -= len(key) + len(existing)
+		}
+		if size > largest {
+			largest = size
+		}
+	}
+	bc.budget.makeRoom(bc, added, largest)
+
+	ok := make([]bool, len(keys))
+	for i := 0; i < len(keys) && i < len(values); i++ {
+		if len(keys[i])+len(values[i]) > bc.limit() {
+			continue
+		}
+		bc.forgetGhost(hashKey(keys[i]))
+		ok[i] = bc.cache.Set(keys[i], values[i])
+	}
+	return ok
+}
+
+// RemoveMulti removes each of keys while holding the budget's lock once,
+// returning the value of each and whether it was found.
+func (bc *BudgetedCache) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+	return removeMulti(bc.cache, keys)
+}
+
+// Len returns the number of bindings in the wrapped cache.
+func (bc *BudgetedCache) Len() int {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+	return bc.cache.Len()
+}
+
+// Stats returns statistics about how many search hits and misses have
+// occurred in the wrapped cache.
+func (bc *BudgetedCache) Stats() *Stats {
+	return bc.cache.Stats()
+}
+
+// OnEvict registers f to be called with each binding the wrapped cache
+// evicts, including those evicted to make room for other caches in the
+// budget.
+func (bc *BudgetedCache) OnEvict(f EvictFunc) {
+	bc.budget.mu.Lock()
+	defer bc.budget.mu.Unlock()
+	bc.cache.OnEvict(f)
+}
@@ -0,0 +1,211 @@
+/******************************************************************************
+ * budget_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    A testing suite for budget.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// The caches in a budget never hold more than its limit between them.
+func TestBudgetLimit(t *testing.T) {
+	for _, victim := range []VictimFunc{LargestVictim, MarginalVictim, FairShareVictim} {
+		budget := NewMemoryBudget(300, victim)
+		caches := []*BudgetedCache{
+			budget.Register(NewLru(300), 1),
+			budget.Register(NewLfu(300), 2),
+			budget.Register(NewLIRS(300, 0.1), 3),
+		}
+
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 20000; i++ {
+			bc := caches[rng.Intn(len(caches))]
+			key := fmt.Sprintf("%04d", rng.Intn(100))
+			if _, ok := bc.Get(key); !ok {
+				bc.Set(key, make([]byte, rng.Intn(20)))
+			}
+
+			capacity, used := 0, 0
+			for _, bc := range caches {
+				usage := bc.Usage()
+				capacity += usage.Capacity
+				used += usage.Used
+				if usage.Used > usage.Capacity {
+					t.Errorf("After %d ops a cache uses %d of %d bytes", i, usage.Used, usage.Capacity)
+					t.FailNow()
+				}
+			}
+			if capacity > 300 || used != budget.Used() {
+				t.Errorf("After %d ops caches have %d bytes of room and use %d, but the budget is 300 and uses %d",
+					i, capacity, used, budget.Used())
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// A cache takes room no other cache uses before evicting anything.
+func TestBudgetFreeRoom(t *testing.T) {
+	budget := NewMemoryBudget(100, LargestVictim)
+	a := budget.Register(NewLru(60), 1)
+	b := budget.Register(NewLru(60), 1)
+	if a.Usage().Capacity != 60 || b.Usage().Capacity != 40 {
+		t.Errorf("Registered caches got %d and %d bytes, expected 60 and 40", a.Usage().Capacity, b.Usage().Capacity)
+		t.FailNow()
+	}
+
+	// b takes the 50 bytes a doesn't use
+	a.Set("a", make([]byte, 9))
+	for i := 0; i < 9; i++ {
+		b.Set(fmt.Sprintf("b%d", i), make([]byte, 8))
+	}
+	if a.Len() != 1 || b.Len() != 9 || b.Usage().Capacity != 90 {
+		t.Errorf("a holds %d bindings and b %d in %d bytes, expected 1 and 9 in 90",
+			a.Len(), b.Len(), b.Usage().Capacity)
+		t.FailNow()
+	}
+
+	// now the largest cache, b, gives up room
+	a.Set("a2", make([]byte, 8))
+	if a.Len() != 2 || b.Len() != 8 {
+		t.Errorf("a holds %d bindings and b %d, expected b to give up one", a.Len(), b.Len())
+		t.FailNow()
+	}
+	if _, ok := b.Get("b0"); ok {
+		t.Errorf("b should have evicted its least recently used binding")
+		t.FailNow()
+	}
+}
+
+// Batches take room from the budget once for all their bindings, and return
+// a result for each key, in order.
+func TestBudgetBatch(t *testing.T) {
+	budget := NewMemoryBudget(100, LargestVictim)
+	a := budget.Register(NewLru(60), 1)
+	b := budget.Register(NewLru(60), 1)
+	a.Set("a", make([]byte, 9))
+
+	keys := []string{}
+	values := [][]byte{}
+	for i := 0; i < 9; i++ {
+		keys = append(keys, fmt.Sprintf("b%d", i))
+		values = append(values, make([]byte, 8))
+	}
+	keys = append(keys, "large")
+	values = append(values, make([]byte, 100))
+	if ok := b.SetMulti(keys, values); fmt.Sprint(ok) != "[true true true true true true true true true false]" {
+		t.Errorf("SetMulti returned %v", ok)
+		t.FailNow()
+	}
+	if a.Len() != 1 || b.Len() != 9 || b.Usage().Capacity != 90 {
+		t.Errorf("a holds %d bindings and b %d in %d bytes, expected 1 and 9 in 90",
+			a.Len(), b.Len(), b.Usage().Capacity)
+		t.FailNow()
+	}
+
+	values, found := b.GetMulti([]string{"b8", "missing", "b0"})
+	if fmt.Sprint(found) != "[true false true]" || len(values[0]) != 8 || b.Stats().Hits != 2 {
+		t.Errorf("GetMulti returned %d bytes and %v with %d hits", len(values[0]), found, b.Stats().Hits)
+		t.FailNow()
+	}
+	values, found = b.RemoveMulti([]string{"b1", "b1"})
+	if fmt.Sprint(found) != "[true false]" || len(values[0]) != 8 || b.Len() != 8 {
+		t.Errorf("RemoveMulti returned %v, leaving %d bindings", found, b.Len())
+		t.FailNow()
+	}
+}
+
+// A cache whose bindings are pinned can't be shrunk, so others evict their
+// own bindings instead of waiting for it to give up room.
+func TestBudgetPinnedVictim(t *testing.T) {
+	budget := NewMemoryBudget(100, LargestVictim)
+	lru := NewLru(0)
+	pinned := budget.Register(lru, 1)
+	other := budget.Register(NewLru(0), 1)
+	for i := 0; i < 8; i++ {
+		key := fmt.Sprintf("p%d", i)
+		pinned.Set(key, make([]byte, 8))
+		lru.Pin(key)
+	}
+
+	for i := 0; i < 10; i++ {
+		if !other.Set(fmt.Sprintf("o%d", i), make([]byte, 8)) {
+			t.Errorf("Failed to set binding %d in the cache beside the pinned one", i)
+			t.FailNow()
+		}
+	}
+	if pinned.Len() != 8 || other.Len() != 2 || budget.Used() != 100 {
+		t.Errorf("Caches hold %d and %d bindings in %d bytes, expected 8 pinned and 2 beside them in 100",
+			pinned.Len(), other.Len(), budget.Used())
+		t.FailNow()
+	}
+}
+
+// With LargestVictim, the largest cache evicts its own bindings rather than
+// taking room from smaller ones.
+func TestBudgetLargest(t *testing.T) {
+	budget := NewMemoryBudget(100, LargestVictim)
+	small := budget.Register(NewLru(0), 1)
+	large := budget.Register(NewLru(0), 1)
+	small.Set("s", make([]byte, 19))
+	for i := 0; i < 20; i++ {
+		large.Set(fmt.Sprintf("l%02d", i), make([]byte, 7))
+	}
+	if small.Len() != 1 || large.Len() != 8 {
+		t.Errorf("small holds %d bindings and large %d, expected 1 and 8", small.Len(), large.Len())
+		t.FailNow()
+	}
+}
+
+// With FairShareVictim, caches that both want more room end up with room in
+// proportion to their weights.
+func TestBudgetFairShare(t *testing.T) {
+	budget := NewMemoryBudget(1000, FairShareVictim)
+	light := budget.Register(NewLru(0), 1)
+	heavy := budget.Register(NewLru(0), 3)
+	for i := 0; i < 1000; i++ {
+		light.Set(fmt.Sprintf("l%04d", i), make([]byte, 5))
+		heavy.Set(fmt.Sprintf("h%04d", i), make([]byte, 5))
+	}
+	// bindings take 10 bytes, so shares can be off by one binding
+	if light.Usage().Used < 240 || light.Usage().Used > 260 || light.Usage().Used+heavy.Usage().Used != 1000 {
+		t.Errorf("Caches with weights 1 and 3 use %d and %d bytes, expected about 250 and 750",
+			light.Usage().Used, heavy.Usage().Used)
+		t.FailNow()
+	}
+}
+
+// With MarginalVictim, a cache whose evicted keys come back takes room from
+// one that scans through keys it never sees again.
+func TestBudgetMarginal(t *testing.T) {
+	budget := NewMemoryBudget(1000, MarginalVictim)
+	loop := budget.Register(NewLru(500), 1)
+	scan := budget.Register(NewLru(500), 1)
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("l%04d", i%55)
+		if _, ok := loop.Get(key); !ok {
+			loop.Set(key, make([]byte, 5))
+		}
+		key = fmt.Sprintf("s%05d", i)
+		if _, ok := scan.Get(key); !ok {
+			scan.Set(key, make([]byte, 5))
+		}
+	}
+
+	if loop.Usage().Used != 550 || loop.Usage().MarginalHitRate == 0 || scan.Usage().MarginalHitRate != 0 {
+		t.Errorf("Looping cache uses %d bytes with marginal hit rate %f, scanning one %d with %f",
+			loop.Usage().Used, loop.Usage().MarginalHitRate, scan.Usage().Used, scan.Usage().MarginalHitRate)
+		t.FailNow()
+	}
+}
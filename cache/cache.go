@@ -3,6 +3,20 @@ package cache
 type Stats struct {
 	Hits   int
 	Misses int
+
+	// ByteHits and ByteMisses count the bytes (key plus value) behind Hits
+	// and Misses. Only size-aware policies such as GDSF track them; a miss is
+	// counted when its binding is added with Set.
+	ByteHits   int
+	ByteMisses int
+
+	// Weights holds the current weight of each expert policy, by name, for
+	// adaptive policies such as LeCaR. It is nil for other policies.
+	Weights map[string]float64
+
+	// window, if set with TrackWindows or TrackIntervals, counts hits and
+	// misses per window for RecentHitRate and Windows.
+	window *hitWindows
 }
 
 func (stats *Stats) Equals(other *Stats) bool {
@@ -42,3 +56,175 @@ type Cache interface {
 	// and misses this cache has resolved over its lifetime.
 	Stats() *Stats
 }
+
+// An EvictFunc is called with each binding a cache evicts to make room. It
+// must not use the cache that evicted the binding.
+type EvictFunc func(key string, value []byte)
+
+// An Evicter is a Cache that reports the bindings it evicts.
+type Evicter interface {
+	Cache
+
+	// OnEvict registers f to be called with each binding the cache evicts.
+	// Bindings removed with Remove are not reported.
+	OnEvict(f EvictFunc)
+}
+
+// A Resizer is a Cache whose capacity can change while it is in use.
+type Resizer interface {
+	Cache
+
+	// Resize changes the capacity of the cache to limit bytes. Shrinking it
+	// evicts bindings according to the cache's policy until they fit, and
+	// growing it makes room right away. Negative limits count as zero.
+	Resize(limit int)
+}
+
+// A Versioner is a Cache with memcached-style conditional stores. Each Set
+// gives the binding a new CAS token, unique across all keys, that Gets
+// returns and CompareAndSwap checks.
+//
+// Policies don't implement it themselves. A CAS token is the same
+// bookkeeping whatever the policy, and it only has to be dropped when the
+// binding is, which every Evicter reports, so NewVersionedCache makes any
+// policy a Versioner.
+type Versioner interface {
+	Cache
+
+	// Gets is Get that also returns the CAS token of the binding.
+	Gets(key string) (value []byte, cas uint64, ok bool)
+
+	// Add sets key only if it isn't bound. Returns true if the binding was
+	// added.
+	Add(key string, value []byte) bool
+
+	// Replace sets key only if it is bound. Returns true if the binding was
+	// replaced.
+	Replace(key string, value []byte) bool
+
+	// CompareAndSwap sets key only if it is bound and its CAS token is still
+	// cas. Returns true if the binding was replaced.
+	CompareAndSwap(key string, value []byte, cas uint64) bool
+}
+
+// A Pinner is a Cache whose bindings can be pinned so that they are never
+// evicted. Pins are counted, so a binding pinned twice stays pinned until it
+// has been unpinned twice. Removing a binding drops its pins. Set fails if
+// the binding doesn't fit in the bytes pinned bindings leave, and Resize
+// never shrinks a Pinner below the size of its pinned bindings.
+type Pinner interface {
+	Cache
+
+	// Pin pins the binding for key. Returns false if key isn't bound.
+	Pin(key string) bool
+
+	// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+	Unpin(key string) bool
+}
+
+// An Inspector is a Cache whose bindings can be looked at without counting as
+// uses or changing Stats, such as by admin tooling.
+type Inspector interface {
+	Cache
+
+	// Peek returns the value associated with the given key, if it exists,
+	// without counting as a use or changing Stats.
+	Peek(key string) (value []byte, ok bool)
+
+	// Contains returns true if the given key is bound, without counting as a
+	// use or changing Stats.
+	Contains(key string) bool
+
+	// Range calls f with each binding in the order the cache would evict
+	// them, coldest first, until f returns false. f must not use the cache.
+	Range(f func(key string, value []byte) bool)
+}
+
+// A Batcher is a Cache that can get, set and remove many keys at once, which
+// saves taking a lock for each key in concurrent wrappers such as SyncCache.
+// Results are in the same order as the keys.
+type Batcher interface {
+	Cache
+
+	// GetMulti gets each of keys as Get does, returning the value of each and
+	// whether it was found.
+	GetMulti(keys []string) (values [][]byte, found []bool)
+
+	// SetMulti sets each of keys to the value at the same index as Set does,
+	// evicting once up front for the bytes the whole batch adds, and returns
+	// whether each binding was added. Keys without a value aren't set.
+	SetMulti(keys []string, values [][]byte) []bool
+
+	// RemoveMulti removes each of keys as Remove does, returning the value of
+	// each and whether it was found.
+	RemoveMulti(keys []string) (values [][]byte, found []bool)
+}
+
+// Keys returns the keys bound in cache in eviction order, coldest first.
+func Keys(cache Inspector) []string {
+	keys := make([]string, 0, cache.Len())
+	cache.Range(func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// onEvict is OnEvict on cache, doing nothing if it isn't an Evicter.
+func onEvict(cache Cache, f EvictFunc) {
+	if evicter, ok := cache.(Evicter); ok {
+		evicter.OnEvict(f)
+	}
+}
+
+// peek is Peek on cache, finding nothing if it isn't an Inspector.
+func peek(cache Cache, key string) (value []byte, ok bool) {
+	inspector, ok := cache.(Inspector)
+	if !ok {
+		return nil, false
+	}
+	return inspector.Peek(key)
+}
+
+// contains is Contains on cache, or false if it isn't an Inspector.
+func contains(cache Cache, key string) bool {
+	inspector, ok := cache.(Inspector)
+	return ok && inspector.Contains(key)
+}
+
+// rangeBindings is Range on cache, calling f with nothing if it isn't an
+// Inspector.
+func rangeBindings(cache Cache, f func(key string, value []byte) bool) {
+	if inspector, ok := cache.(Inspector); ok {
+		inspector.Range(f)
+	}
+}
+
+// resize is Resize on cache, doing nothing if it isn't a Resizer.
+func resize(cache Cache, limit int) {
+	if resizer, ok := cache.(Resizer); ok {
+		resizer.Resize(limit)
+	}
+}
+
+// pin is Pin on cache, or false if it isn't a Pinner.
+func pin(cache Cache, key string) bool {
+	pinner, ok := cache.(Pinner)
+	return ok && pinner.Pin(key)
+}
+
+// unpin is Unpin on cache, or false if it isn't a Pinner.
+func unpin(cache Cache, key string) bool {
+	pinner, ok := cache.(Pinner)
+	return ok && pinner.Unpin(key)
+}
+
+// evictHandlers are the EvictFuncs registered with a cache.
+type evictHandlers []EvictFunc
+
+// evicted calls every handler with an evicted binding.
+func (handlers evictHandlers) evicted(key string, value []byte) {
+	for _, f := range handlers {
+		f(key, value)
+	}
+}
@@ -0,0 +1,617 @@
+// Package cachetest checks that implementations of cache.Cache keep the
+// contract documented on the interface, whatever their eviction policy.
+package cachetest
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"cos316.princeton.edu/assignment3/cache"
+)
+
+// A Factory returns an empty cache with a capacity to store limit bytes.
+type Factory func(limit int) cache.Cache
+
+// Conformance runs caches made by factory through fixed and randomized
+// sequences of operations, failing t wherever they break the Cache contract.
+// Which bindings are evicted is up to the cache; the suite only checks that
+// bindings it still returns hold the value last set for them.
+func Conformance(t *testing.T, factory Factory) {
+	t.Run("Empty", func(t *testing.T) { testEmpty(t, factory) })
+	t.Run("TooLarge", func(t *testing.T) { testTooLarge(t, factory) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, factory) })
+	t.Run("Remove", func(t *testing.T) { testRemove(t, factory) })
+	t.Run("Model", func(t *testing.T) {
+		for seed := int64(1); seed <= 20; seed++ {
+			newModel(t, factory, 64, seed).run(2000)
+		}
+	})
+	if _, ok := factory(0).(cache.Resizer); ok {
+		t.Run("Resize", func(t *testing.T) {
+			testResize(t, factory)
+			for seed := int64(1); seed <= 20; seed++ {
+				m := newModel(t, factory, 64, seed)
+				m.resizes = true
+				m.run(2000)
+			}
+		})
+	}
+	if _, ok := factory(0).(cache.Pinner); ok {
+		t.Run("Pin", func(t *testing.T) {
+			testPin(t, factory)
+			for seed := int64(1); seed <= 20; seed++ {
+				m := newModel(t, factory, 64, seed)
+				m.pins = true
+				_, m.resizes = m.cache.(cache.Resizer)
+				m.run(2000)
+			}
+		})
+	}
+	if _, ok := factory(0).(cache.Versioner); ok {
+		t.Run("Versioned", func(t *testing.T) {
+			testVersioned(t, factory)
+			for seed := int64(1); seed <= 20; seed++ {
+				m := newModel(t, factory, 64, seed)
+				m.versions = true
+				m.run(2000)
+			}
+		})
+	}
+}
+
+func testEmpty(t *testing.T, factory Factory) {
+	for _, limit := range []int{0, 1, 100} {
+		c := factory(limit)
+		if c.MaxStorage() != limit || c.RemainingStorage() != limit || c.Len() != 0 {
+			t.Errorf("New cache with limit %d has MaxStorage %d, RemainingStorage %d and Len %d",
+				limit, c.MaxStorage(), c.RemainingStorage(), c.Len())
+			t.FailNow()
+		}
+		if c.Stats().Hits != 0 || c.Stats().Misses != 0 {
+			t.Errorf("New cache has stats %+v", *c.Stats())
+			t.FailNow()
+		}
+		if _, ok := c.Get("key"); ok {
+			t.Errorf("New cache with limit %d found a binding", limit)
+			t.FailNow()
+		}
+	}
+}
+
+func testTooLarge(t *testing.T, factory Factory) {
+	c := factory(10)
+	if c.Set("123456", []byte("123456")) {
+		t.Errorf("Set should reject a binding larger than MaxStorage")
+		t.FailNow()
+	}
+	if c.Len() != 0 || c.RemainingStorage() != 10 {
+		t.Errorf("A rejected Set should not change the cache")
+		t.FailNow()
+	}
+
+	// a rejected update leaves the binding as it was
+	c.Set("key", []byte("value"))
+	if c.Set("key", []byte("a much longer value")) {
+		t.Errorf("Set should reject an update larger than MaxStorage")
+		t.FailNow()
+	}
+	if value, ok := c.Get("key"); ok && string(value) != "value" {
+		t.Errorf("A rejected update should not store its value, but key has value %s", value)
+		t.FailNow()
+	}
+	checkUsed(t, c, []string{"key"})
+}
+
+func testUpdate(t *testing.T, factory Factory) {
+	c := factory(100)
+	c.Set("key", []byte("first"))
+	if !c.Set("key", []byte("second value")) {
+		t.Errorf("Failed to update binding with key: key")
+		t.FailNow()
+	}
+	checkValue(t, c, "key", "second value")
+	if c.Len() != 1 || c.RemainingStorage() != 100-len("key")-len("second value") {
+		t.Errorf("After an update Len is %d and RemainingStorage %d", c.Len(), c.RemainingStorage())
+		t.FailNow()
+	}
+
+	// an update that needs room evicts other bindings, never the one updated
+	keys := []string{"key"}
+	for i := 0; i < 8; i++ {
+		key := fmt.Sprintf("other%d", i)
+		c.Set(key, []byte(key))
+		keys = append(keys, key)
+	}
+	value := strings.Repeat("v", 80)
+	if !c.Set("key", []byte(value)) {
+		t.Errorf("Failed to update binding with key: key")
+		t.FailNow()
+	}
+	checkValue(t, c, "key", value)
+	checkUsed(t, c, keys)
+
+	// and an update to a smaller value frees its room
+	c.Set("key", nil)
+	checkValue(t, c, "key", "")
+	checkUsed(t, c, keys)
+}
+
+func testRemove(t *testing.T, factory Factory) {
+	c := factory(100)
+	c.Set("key", []byte("value"))
+	c.Get("key")
+	c.Get("missing")
+	stats := *c.Stats()
+
+	value, ok := c.Remove("key")
+	if !ok || string(value) != "value" {
+		t.Errorf("Failed to remove binding with key: key")
+		t.FailNow()
+	}
+	if _, ok := c.Remove("key"); ok {
+		t.Errorf("Removed binding with key key twice")
+		t.FailNow()
+	}
+	if _, ok := c.Remove("missing"); ok {
+		t.Errorf("Removed a binding that was never set")
+		t.FailNow()
+	}
+	if !c.Stats().Equals(&stats) {
+		t.Errorf("Remove changed stats from %+v to %+v", stats, *c.Stats())
+		t.FailNow()
+	}
+	if c.Len() != 0 || c.RemainingStorage() != 100 {
+		t.Errorf("After removing its only binding Len is %d and RemainingStorage %d", c.Len(), c.RemainingStorage())
+		t.FailNow()
+	}
+	if _, ok := c.Get("key"); ok {
+		t.Errorf("Found binding with key key after removing it")
+		t.FailNow()
+	}
+}
+
+func testResize(t *testing.T, factory Factory) {
+	c := factory(100)
+	keys := []string{}
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key%d", i)
+		c.Set(key, []byte("value"))
+		keys = append(keys, key)
+	}
+
+	// shrinking evicts until the bindings fit
+	c.(cache.Resizer).Resize(50)
+	if c.MaxStorage() != 50 || c.RemainingStorage() < 0 || c.Len() > 6 {
+		t.Errorf("After Resize(50) MaxStorage is %d, RemainingStorage %d and Len %d",
+			c.MaxStorage(), c.RemainingStorage(), c.Len())
+		t.FailNow()
+	}
+	checkUsed(t, c, keys)
+
+	// growing makes room for more bindings right away
+	c.(cache.Resizer).Resize(200)
+	for _, key := range keys {
+		c.Set(key, []byte("value"))
+	}
+	if c.MaxStorage() != 200 || c.Len() != len(keys) {
+		t.Errorf("After Resize(200) MaxStorage is %d and Len %d, expected %d bindings",
+			c.MaxStorage(), c.Len(), len(keys))
+		t.FailNow()
+	}
+	checkUsed(t, c, keys)
+
+	c.(cache.Resizer).Resize(0)
+	if c.MaxStorage() != 0 || c.RemainingStorage() != 0 || c.Len() != 0 {
+		t.Errorf("After Resize(0) MaxStorage is %d, RemainingStorage %d and Len %d",
+			c.MaxStorage(), c.RemainingStorage(), c.Len())
+		t.FailNow()
+	}
+}
+
+func testPin(t *testing.T, factory Factory) {
+	c := factory(100)
+	p := c.(cache.Pinner)
+	if p.Pin("missing") || p.Unpin("missing") {
+		t.Errorf("Pin and Unpin should fail for a key that isn't bound")
+		t.FailNow()
+	}
+
+	// pinned bindings survive any number of other Sets
+	c.Set("pinned", []byte("value"))
+	p.Pin("pinned")
+	p.Pin("pinned")
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+	checkValue(t, c, "pinned", "value")
+
+	// a binding too large for the unpinned bytes is rejected
+	if c.Set("large", make([]byte, 100-len("large")-len("pinned")-len("value")+1)) {
+		t.Errorf("Set should fail for a binding that only fits by evicting a pinned one")
+		t.FailNow()
+	}
+	checkValue(t, c, "pinned", "value")
+
+	// a binding stays pinned until it is unpinned as often as it was pinned
+	if !p.Unpin("pinned") || !p.Unpin("pinned") || p.Unpin("pinned") {
+		t.Errorf("Unpin should succeed twice for a binding pinned twice, then fail")
+		t.FailNow()
+	}
+	if !c.Set("large", make([]byte, 100-len("large"))) {
+		t.Errorf("Set should evict bindings that are no longer pinned")
+		t.FailNow()
+	}
+
+	// removing a binding drops its pins
+	p.Pin("large")
+	c.Remove("large")
+	if p.Unpin("large") {
+		t.Errorf("Unpin should fail for a binding that was removed while pinned")
+		t.FailNow()
+	}
+}
+
+func testVersioned(t *testing.T, factory Factory) {
+	c := factory(100)
+	v := c.(cache.Versioner)
+	if v.Replace("key", []byte("a")) || !v.Add("key", []byte("a")) || v.Add("key", []byte("b")) {
+		t.Errorf("Add should only succeed for an unbound key, and Replace for a bound one")
+		t.FailNow()
+	}
+	if !v.Replace("key", []byte("c")) {
+		t.Errorf("Replace should succeed for a bound key")
+		t.FailNow()
+	}
+	checkValue(t, c, "key", "c")
+
+	_, cas, ok := v.Gets("key")
+	if !ok || !v.CompareAndSwap("key", []byte("d"), cas) || v.CompareAndSwap("key", []byte("e"), cas) {
+		t.Errorf("CompareAndSwap should succeed once with the token from Gets")
+		t.FailNow()
+	}
+	checkValue(t, c, "key", "d")
+
+	// an evicted binding is unbound
+	for i := 0; i < 20; i++ {
+		c.Set(fmt.Sprintf("other%d", i), []byte("value"))
+	}
+	if _, ok := c.Get("key"); !ok && (v.Replace("key", []byte("f")) || !v.Add("key", []byte("f"))) {
+		t.Errorf("Add and Replace should treat an evicted key as unbound")
+		t.FailNow()
+	}
+}
+
+// Fails test t if c doesn't hold key bound to value.
+func checkValue(t *testing.T, c cache.Cache, key string, value string) {
+	t.Helper()
+	got, ok := c.Get(key)
+	if !ok || string(got) != value {
+		t.Errorf("Expected key %s to have value %q, but got %q (found: %t)", key, value, got, ok)
+		t.FailNow()
+	}
+}
+
+// Fails test t if the bindings c holds among keys don't account for its Len
+// and used storage.
+func checkUsed(t *testing.T, c cache.Cache, keys []string) {
+	t.Helper()
+	count, used := 0, 0
+	for _, key := range keys {
+		if value, ok := c.Get(key); ok {
+			count++
+			used += len(key) + len(value)
+		}
+	}
+	if count != c.Len() || used != c.MaxStorage()-c.RemainingStorage() {
+		t.Errorf("Cache holds %d bindings of %d bytes, but has Len %d and uses %d bytes",
+			count, used, c.Len(), c.MaxStorage()-c.RemainingStorage())
+		t.FailNow()
+	}
+}
+
+// A model runs random operations against a cache and a reference map of the
+// bindings the cache may still hold.
+type model struct {
+	t        *testing.T
+	cache    cache.Cache
+	limit    int
+	size     int  // limit values are sized for
+	resizes  bool // whether to resize the cache, which must be a Resizer
+	pins     bool // whether to pin bindings, which the cache must be a Pinner to
+	versions bool // whether to use Add, Replace and CompareAndSwap of a Versioner
+	seed     int64
+	rand     *rand.Rand
+
+	keys     []string
+	bindings map[string]string // bindings not known to be evicted or removed
+	pinned   map[string]int    // times each binding is pinned
+	hits     int
+	misses   int
+	sets     int
+	history  []string
+}
+
+func newModel(t *testing.T, factory Factory, limit int, seed int64) *model {
+	m := &model{
+		t:        t,
+		cache:    factory(limit),
+		limit:    limit,
+		size:     limit,
+		seed:     seed,
+		rand:     rand.New(rand.NewSource(seed)),
+		bindings: map[string]string{},
+		pinned:   map[string]int{},
+	}
+	for i := 0; i < 16; i++ {
+		m.keys = append(m.keys, fmt.Sprintf("k%d", i))
+	}
+	return m
+}
+
+// run applies n random operations, checking the cache after each one.
+func (m *model) run(n int) {
+	for i := 0; i < n; i++ {
+		if m.resizes && m.rand.Intn(50) == 0 {
+			m.resize(m.rand.Intn(2*m.size + 1))
+		}
+		key := m.keys[m.rand.Intn(len(m.keys))]
+		if m.pins && m.rand.Intn(10) == 0 {
+			if m.rand.Intn(2) == 0 {
+				m.pin(key)
+			} else {
+				m.unpin(key)
+			}
+		}
+		switch op := m.rand.Intn(10); {
+		case op < 4:
+			m.get(key)
+		case op < 8 && m.versions && m.rand.Intn(2) == 0:
+			m.version(key)
+		case op < 8:
+			m.set(key, m.value())
+			if m.rand.Intn(2) == 0 {
+				m.get(key)
+			}
+		case op < 9:
+			m.remove(key)
+		default:
+			m.checkAll()
+		}
+		m.check()
+	}
+	m.checkAll()
+}
+
+// value returns a distinct value, usually small, sometimes too large to fit.
+func (m *model) value() string {
+	m.sets++
+	size := m.rand.Intn(m.size / 3)
+	if m.rand.Intn(20) == 0 {
+		size = m.size + m.rand.Intn(m.size)
+	}
+	value := fmt.Sprintf("%d.", m.sets)
+	for len(value) < size {
+		value += value
+	}
+	return value[:size]
+}
+
+func (m *model) get(key string) {
+	m.log("Get(%s)", key)
+	value, ok := m.cache.Get(key)
+	m.got(key, value, ok)
+}
+
+// got checks the result of a Get of key.
+func (m *model) got(key string, value []byte, ok bool) {
+	expected, present := m.bindings[key]
+	if ok {
+		m.hits++
+		if !present || string(value) != expected {
+			m.fail("Get(%s) returned %q, but the last value set was %q (present: %t)", key, value, expected, present)
+		}
+	} else {
+		m.misses++
+		m.evicted(key)
+	}
+}
+
+func (m *model) set(key string, value string) {
+	m.log("Set(%s, %d bytes)", key, len(value))
+	ok := m.cache.Set(key, []byte(value))
+	fits := len(key)+len(value) <= m.limit-m.pinnedSize(key)
+	if ok != fits {
+		m.fail("Set(%s) of %d bytes returned %t with MaxStorage %d", key, len(key)+len(value), ok, m.limit)
+	}
+	if ok {
+		m.bindings[key] = value
+		if m.cache.RemainingStorage() < 0 {
+			m.fail("RemainingStorage is %d after Set", m.cache.RemainingStorage())
+		}
+	}
+}
+
+// version runs Add, Replace or CompareAndSwap for key with a new value.
+func (m *model) version(key string) {
+	v := m.cache.(cache.Versioner)
+	value := m.value()
+	fits := len(key)+len(value) <= m.limit-m.pinnedSize(key)
+	_, present := m.bindings[key]
+
+	var ok bool
+	switch m.rand.Intn(3) {
+	case 0:
+		m.log("Add(%s, %d bytes)", key, len(value))
+		// a bound key may have been evicted, but an unbound one isn't held
+		if ok = v.Add(key, []byte(value)); ok != fits && !present {
+			m.fail("Add(%s) of %d bytes returned %t for an unbound key with MaxStorage %d",
+				key, len(key)+len(value), ok, m.limit)
+		}
+	case 1:
+		m.log("Replace(%s, %d bytes)", key, len(value))
+		if ok = v.Replace(key, []byte(value)); ok && !present {
+			m.fail("Replace(%s) succeeded, but it isn't bound", key)
+		}
+		if !ok && fits {
+			m.evicted(key)
+		}
+	default:
+		m.log("Gets(%s)", key)
+		got, cas, found := v.Gets(key)
+		m.got(key, got, found)
+		if !found {
+			if ok = v.CompareAndSwap(key, []byte(value), cas); ok {
+				m.fail("CompareAndSwap(%s) succeeded, but it isn't bound", key)
+			}
+			break
+		}
+		m.log("CompareAndSwap(%s, %d bytes)", key, len(value))
+		if v.CompareAndSwap(key, []byte(value), cas+1) {
+			m.fail("CompareAndSwap(%s) succeeded with a token Gets didn't return", key)
+		}
+		if ok = v.CompareAndSwap(key, []byte(value), cas); ok != fits {
+			m.fail("CompareAndSwap(%s) of %d bytes returned %t with the token from Gets and MaxStorage %d",
+				key, len(key)+len(value), ok, m.limit)
+		}
+	}
+	if ok {
+		if !fits {
+			m.fail("%d bytes were stored for %s with MaxStorage %d", len(key)+len(value), key, m.limit)
+		}
+		m.bindings[key] = value
+	}
+}
+
+// evicted records that key turned out not to be bound.
+func (m *model) evicted(key string) {
+	if m.pinned[key] > 0 {
+		m.fail("%s was found to be evicted, but it is pinned", key)
+	}
+	delete(m.bindings, key)
+}
+
+func (m *model) remove(key string) {
+	m.log("Remove(%s)", key)
+	length := m.cache.Len()
+	remaining := m.cache.RemainingStorage()
+	value, ok := m.cache.Remove(key)
+	expected, present := m.bindings[key]
+	delete(m.bindings, key)
+	delete(m.pinned, key)
+	if !ok {
+		return
+	}
+
+	if !present || string(value) != expected {
+		m.fail("Remove(%s) returned %q, but the last value set was %q (present: %t)", key, value, expected, present)
+	}
+	if m.cache.Len() != length-1 || m.cache.RemainingStorage() != remaining+len(key)+len(value) {
+		m.fail("Remove(%s) changed Len from %d to %d and RemainingStorage from %d to %d",
+			key, length, m.cache.Len(), remaining, m.cache.RemainingStorage())
+	}
+}
+
+// resize changes the capacity of the cache and checks the bindings it keeps
+// fit.
+func (m *model) resize(limit int) {
+	m.log("Resize(%d)", limit)
+	m.cache.(cache.Resizer).Resize(limit)
+	if pinned := m.pinnedSize(""); limit < pinned {
+		limit = pinned
+	}
+	m.limit = limit
+	m.check()
+	m.checkAll()
+}
+
+// pin pins key, which the cache must still hold if it returns true.
+func (m *model) pin(key string) {
+	m.log("Pin(%s)", key)
+	if !m.cache.(cache.Pinner).Pin(key) {
+		if m.pinned[key] > 0 {
+			m.fail("Pin(%s) failed, but it is pinned", key)
+		}
+		delete(m.bindings, key)
+		return
+	}
+	if _, present := m.bindings[key]; !present {
+		m.fail("Pin(%s) succeeded, but it isn't bound", key)
+	}
+	m.pinned[key]++
+}
+
+func (m *model) unpin(key string) {
+	m.log("Unpin(%s)", key)
+	if ok := m.cache.(cache.Pinner).Unpin(key); ok != (m.pinned[key] > 0) {
+		m.fail("Unpin(%s) returned %t, but it was pinned %d times", key, ok, m.pinned[key])
+	}
+	if m.pinned[key]--; m.pinned[key] <= 0 {
+		delete(m.pinned, key)
+	}
+}
+
+// pinnedSize returns the bytes taken by pinned bindings other than the one for
+// except.
+func (m *model) pinnedSize(except string) int {
+	size := 0
+	for key := range m.pinned {
+		if key != except {
+			size += len(key) + len(m.bindings[key])
+		}
+	}
+	return size
+}
+
+// check verifies what can be checked without using the cache.
+func (m *model) check() {
+	c := m.cache
+	if c.MaxStorage() != m.limit {
+		m.fail("MaxStorage changed from %d to %d", m.limit, c.MaxStorage())
+	}
+	if c.RemainingStorage() < 0 || c.RemainingStorage() > m.limit {
+		m.fail("RemainingStorage is %d with MaxStorage %d", c.RemainingStorage(), m.limit)
+	}
+	if c.Len() < 0 || c.Len() > len(m.bindings) {
+		m.fail("Len is %d, but at most %d bindings may be held", c.Len(), len(m.bindings))
+	}
+	if c.Stats().Hits != m.hits || c.Stats().Misses != m.misses {
+		m.fail("Stats count %d hits and %d misses, but there were %d and %d",
+			c.Stats().Hits, c.Stats().Misses, m.hits, m.misses)
+	}
+}
+
+// checkAll gets every key, checking the bindings found account for Len and
+// the storage used.
+func (m *model) checkAll() {
+	m.log("checkAll")
+	count, used := 0, 0
+	for _, key := range m.keys {
+		m.get(key)
+		if value, ok := m.bindings[key]; ok {
+			count++
+			used += len(key) + len(value)
+		}
+	}
+	c := m.cache
+	if count != c.Len() || used != c.MaxStorage()-c.RemainingStorage() {
+		m.fail("Cache holds %d bindings of %d bytes, but has Len %d and uses %d bytes",
+			count, used, c.Len(), c.MaxStorage()-c.RemainingStorage())
+	}
+}
+
+// log remembers an operation, to show the operations leading to a failure.
+func (m *model) log(format string, args ...interface{}) {
+	m.history = append(m.history, fmt.Sprintf(format, args...))
+	if len(m.history) > 20 {
+		m.history = m.history[1:]
+	}
+}
+
+func (m *model) fail(format string, args ...interface{}) {
+	m.t.Helper()
+	m.t.Errorf("Seed %d: %s\nLast operations:\n\t%s",
+		m.seed, fmt.Sprintf(format, args...), strings.Join(m.history, "\n\t"))
+	m.t.FailNow()
+}
@@ -0,0 +1,87 @@
+/******************************************************************************
+ * conformance_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    Runs every policy through the cachetest conformance suite
+ ******************************************************************************/
+
+package cache_test
+
+import (
+	"io"
+	"testing"
+
+	"cos316.princeton.edu/assignment3/cache"
+	"cos316.princeton.edu/assignment3/cache/cachetest"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestConformance(t *testing.T) {
+	policies := []struct {
+		name    string
+		factory cachetest.Factory
+	}{
+		{"LRU", func(limit int) cache.Cache { return cache.NewLru(limit) }},
+		{"LFU", func(limit int) cache.Cache { return cache.NewLfu(limit) }},
+		{"LogLFU", func(limit int) cache.Cache { return cache.NewLogLfu(limit, 0.1, 10.0) }},
+		{"LinLFU", func(limit int) cache.Cache { return cache.NewLinearLfu(limit, 0.5) }},
+		{"ExpLFU", func(limit int) cache.Cache { return cache.NewExpLfu(limit, 0.1, 0.5) }},
+		{"LFU DA", func(limit int) cache.Cache { return cache.NewLFUDA(limit) }},
+		{"LIRS", func(limit int) cache.Cache { return cache.NewLIRS(limit, 0.1) }},
+		{"GDSF", func(limit int) cache.Cache { return cache.NewGDSF(limit, cache.UniformCost) }},
+		{"LRU-K", func(limit int) cache.Cache { return cache.NewLRUK(limit, 2, 2, 10*limit) }},
+		{"LeCaR", func(limit int) cache.Cache { return cache.NewLeCaR(limit, 0.45, 0.9, 1) }},
+		{"FIFO", func(limit int) cache.Cache { return cache.NewFIFO(limit) }},
+		{"Random", func(limit int) cache.Cache { return cache.NewRandom(limit, 1) }},
+		{"Sampled", func(limit int) cache.Cache { return cache.NewSampled(limit, 5, cache.SampledLFU, 1) }},
+		{"Sizing", func(limit int) cache.Cache { return cache.NewSizingCache(cache.NewLru(limit)) }},
+		{"Versioned", func(limit int) cache.Cache { return cache.NewVersionedCache(cache.NewLfu(limit)) }},
+		{"VersionedLIRS", func(limit int) cache.Cache { return cache.NewVersionedCache(cache.NewLIRS(limit, 0.1)) }},
+		{"Sync", func(limit int) cache.Cache { return cache.NewSyncCache(cache.NewFIFO(limit)) }},
+		{"Recording", func(limit int) cache.Cache {
+			rc, _ := cache.NewRecordingCache(cache.NewLRUK(limit, 2, 2, 10*limit), io.Discard, cache.RecordingOptions{})
+			return rc
+		}},
+		{"StoredThrough", func(limit int) cache.Cache {
+			return cache.NewStoredCache(cache.NewLru(limit), nullStore{}, cache.WriteThrough, 0)
+		}},
+		{"StoredBack", func(limit int) cache.Cache {
+			return cache.NewStoredCache(cache.NewLIRS(limit, 0.1), nullStore{}, cache.WriteBack, 0)
+		}},
+		{"Tagged", func(limit int) cache.Cache { return cache.NewTaggedCache(cache.NewLeCaR(limit, 0.45, 0.9, 1)) }},
+		{"Budget", func(limit int) cache.Cache {
+			budget := cache.NewMemoryBudget(limit, cache.LargestVictim)
+			budget.Register(cache.NewLru(limit/2), 1)
+			return budget.Register(cache.NewLIRS(limit, 0.1), 1)
+		}},
+		{"Namespaced", func(limit int) cache.Cache {
+			nc := cache.NewNamespacedCache(limit, func(limit int) cache.BudgetMember { return cache.NewGDSF(limit, cache.ByteCost) })
+			nc.AddTenant("other", 0, 0)
+			nc.AddTenant("tenant", 0, 0)
+			nc.Set("other", "key", []byte("value"))
+			return nc.Tenant("tenant")
+		}},
+	}
+
+	for _, policy := range policies {
+		t.Run(policy.name, func(t *testing.T) {
+			cachetest.Conformance(t, policy.factory)
+		})
+	}
+}
+
+/******************************************************************************/
+/*                                 Helpers                                    */
+/******************************************************************************/
+
+// A nullStore is a Store that keeps nothing, so a StoredCache in front of it
+// binds only what its cache does.
+type nullStore struct{}
+
+func (nullStore) Load(key string) ([]byte, bool, error) { return nil, false, nil }
+func (nullStore) Store(key string, value []byte) error  { return nil }
+func (nullStore) Delete(key string) error               { return nil }
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"errors"
+)
+
+// Errors returned by SetE, GetE, RemoveE and StoredCache.
+var (
+	// ErrTooLarge is returned for a binding larger than MaxStorage.
+	ErrTooLarge = errors.New("cache: binding is larger than the cache")
+
+	// ErrNoRoom is returned for a binding that fits in MaxStorage, but not in
+	// the bytes the cache can free for it, such as when the rest of the cache
+	// holds pinned bindings.
+	ErrNoRoom = errors.New("cache: not enough room can be freed for binding")
+
+	// ErrNotFound is returned for a key that isn't bound.
+	ErrNotFound = errors.New("cache: key not found")
+
+	// ErrClosed is returned for any operation on a StoredCache after Close.
+	ErrClosed = errors.New("cache: cache is closed")
+
+	// ErrNotCached is returned for a binding a StoredCache wrote to its Store
+	// but couldn't cache.
+	ErrNotCached = errors.New("cache: binding was stored but not cached")
+)
+
+// A closer is a cache that can be closed, such as a StoredCache or a wrapper
+// of one.
+type closer interface {
+	isClosed() bool
+}
+
+// isClosed returns true if cache is a closer that has been closed.
+func isClosed(cache Cache) bool {
+	c, ok := cache.(closer)
+	return ok && c.isClosed()
+}
+
+// A setErrorer is a cache whose Sets can fail for reasons other than room,
+// such as a StoredCache or a wrapper of one.
+type setErrorer interface {
+	// setError returns why the last Set failed, or nil if it was for lack
+	// of room
+	setError() error
+}
+
+// setError returns why the last Set on cache failed, if it is a setErrorer.
+func setError(cache Cache) error {
+	if s, ok := cache.(setErrorer); ok {
+		return s.setError()
+	}
+	return nil
+}
+
+// SetE is cache.Set, but returns nil if the binding was added, or why it
+// wasn't: ErrTooLarge, ErrNoRoom, or for a StoredCache, ErrClosed,
+// ErrNotCached or the Store's error.
+func SetE(cache Cache, key string, value []byte) error {
+	if isClosed(cache) {
+		return ErrClosed
+	}
+	if cache.Set(key, value) {
+		return nil
+	}
+	if err := setError(cache); err != nil {
+		return err
+	}
+	if len(key)+len(value) > cache.MaxStorage() {
+		return ErrTooLarge
+	}
+	return ErrNoRoom
+}
+
+// GetE is cache.Get, but returns ErrNotFound if key isn't bound, or
+// ErrClosed.
+func GetE(cache Cache, key string) ([]byte, error) {
+	if isClosed(cache) {
+		return nil, ErrClosed
+	}
+	value, ok := cache.Get(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+// RemoveE is cache.Remove, but returns ErrNotFound if key isn't bound, or
+// ErrClosed.
+func RemoveE(cache Cache, key string) ([]byte, error) {
+	if isClosed(cache) {
+		return nil, ErrClosed
+	}
+	value, ok := cache.Remove(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
@@ -0,0 +1,132 @@
+/******************************************************************************
+ * errors_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    A testing suite for errors.go and for evicting from caches that have
+ *    nothing to evict
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// SetE, GetE and RemoveE tell why an operation failed.
+func TestErrors(t *testing.T) {
+	lru := NewLru(30)
+	if err := SetE(lru, "large", make([]byte, 30)); err != ErrTooLarge {
+		t.Errorf("SetE of a binding larger than the cache returned %v", err)
+		t.FailNow()
+	}
+	if err := SetE(lru, "pinned", make([]byte, 10)); err != nil {
+		t.Errorf("SetE of a binding that fits returned %v", err)
+		t.FailNow()
+	}
+	lru.Pin("pinned")
+	if err := SetE(lru, "key", make([]byte, 12)); err != ErrNoRoom {
+		t.Errorf("SetE of a binding that only fits by evicting a pinned one returned %v", err)
+		t.FailNow()
+	}
+
+	if _, err := GetE(lru, "key"); err != ErrNotFound {
+		t.Errorf("GetE of a missing key returned %v", err)
+		t.FailNow()
+	}
+	if value, err := RemoveE(lru, "pinned"); err != nil || len(value) != 10 {
+		t.Errorf("RemoveE of a bound key returned %d bytes and %v", len(value), err)
+		t.FailNow()
+	}
+	if _, err := RemoveE(lru, "pinned"); err != ErrNotFound {
+		t.Errorf("RemoveE of a removed key returned %v", err)
+		t.FailNow()
+	}
+}
+
+// Evicting from a cache that is empty or holds only pinned bindings does
+// nothing.
+func TestEvictNothing(t *testing.T) {
+	for _, policy := range experimentPolicies {
+		cache := policy.factory(100)
+		evictOne(cache)
+
+		for i := 0; i < 3; i++ {
+			key := fmt.Sprintf("key%d", i)
+			cache.Set(key, []byte("value"))
+			cache.(Pinner).Pin(key)
+		}
+		evictOne(cache)
+		cache.(Resizer).Resize(0)
+		if cache.Len() != 3 || cache.MaxStorage() != 3*len("key0value") {
+			t.Errorf("%s holds %d pinned bindings with MaxStorage %d after evicting, expected 3",
+				policy.name, cache.Len(), cache.MaxStorage())
+			t.FailNow()
+		}
+		checkInvariants(t, policy.name, cache)
+	}
+}
+
+// Wrappers of a cache that is only an Evicter find nothing with Peek,
+// Contains and Range, and can't Resize, Pin or Unpin it, rather than panicking.
+func TestWrapPlainEvicter(t *testing.T) {
+	type plain struct{ Evicter }
+	wrappers := []Cache{
+		NewSyncCache(plain{NewLru(100)}),
+		NewSizingCache(plain{NewLru(100)}),
+		NewVersionedCache(plain{NewLru(100)}),
+		NewTaggedCache(plain{NewLru(100)}),
+	}
+	for _, cache := range wrappers {
+		cache.Set("key", []byte("value"))
+		inspector := cache.(Inspector)
+		if _, ok := inspector.Peek("key"); ok || inspector.Contains("key") || len(Keys(inspector)) != 0 {
+			t.Errorf("%T inspected a cache that isn't an Inspector", cache)
+			t.FailNow()
+		}
+		cache.(Resizer).Resize(0)
+		if cache.(Pinner).Pin("key") || cache.(Pinner).Unpin("key") || cache.MaxStorage() != 100 {
+			t.Errorf("%T resized or pinned a cache that isn't a Resizer or Pinner", cache)
+			t.FailNow()
+		}
+	}
+}
+
+/******************************************************************************/
+/*                                 Helpers                                    */
+/******************************************************************************/
+
+// Calls the Evict function of the policy of cache.
+func evictOne(cache Cache) {
+	switch c := cache.(type) {
+	case *LRU:
+		EvictLRU(c)
+	case *LFU:
+		EvictLFU(c)
+	case *LogLFU:
+		EvictLogLFU(c)
+	case *LinearLFU:
+		EvictLinearLFU(c)
+	case *ExpLFU:
+		EvictExpLFU(c)
+	case *LFUDA:
+		EvictLFUDA(c)
+	case *LIRS:
+		EvictLIRS(c)
+	case *GDSF:
+		EvictGDSF(c)
+	case *LRUK:
+		EvictLRUK(c)
+	case *LeCaR:
+		EvictLeCaR(c)
+	case *FIFO:
+		EvictFIFO(c)
+	case *Random:
+		EvictRandom(c)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"math"
 )
 
-// An ExpLFU is a fixed-size in-memory cache with least-frequently-used eviction
+// An ExpLFU is a fixed-size in-memory cache with least-frequently-used eviction.
+// Priorities grow exponentially with the number of cache accesses, so they
+// are kept as their natural logarithms to stay finite.
 type ExpLFU struct {
 	// whatever fields you want here
 	pq       PriorityQueue
@@ -14,6 +16,8 @@ type ExpLFU struct {
 	maxSize  int
 	currSize int
 	stats    *Stats
+	onEvict  evictHandlers
+	pinned   pins
 
 	alpha         float64
 	beta          float64
@@ -41,6 +45,7 @@ func NewExpLfu(limit int, alpha float64, beta float64) *ExpLFU {
 	// Constant Base for the log operation
 	cache.beta = beta
 	cache.cacheAccesses = 0
+	cache.pinned = pins{}
 	return cache
 }
 
@@ -54,6 +59,18 @@ func (lfu *ExpLFU) RemainingStorage() int {
 	return lfu.maxSize - lfu.currSize
 }
 
+// Resize changes the capacity of this ExpLFU to limit bytes, evicting bindings
+// in the usual order until they fit.
+func (lfu *ExpLFU) Resize(limit int) {
+	if pinned := lfu.pinned.size(lfu.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	lfu.maxSize = limit
+	for lfu.currSize > lfu.maxSize {
+		EvictExpLFU(lfu)
+	}
+}
+
 // Get returns the value associated with the given key, if it exists.
 // This operation counts as a "use" for that key-value pair
 // ok is true if a value was found and false otherwise.
@@ -62,7 +79,7 @@ func (lfu *ExpLFU) Get(key string) (value []byte, ok bool) {
 	valPointer := lfu.lookup[key]
 
 	if valPointer == nil {
-		lfu.stats.Misses++
+		lfu.stats.miss()
 		return nil, false
 	}
 
@@ -82,14 +99,25 @@ func (lfu *ExpLFU) Get(key string) (value []byte, ok bool) {
 	// }
 	// fmt.Println()
 
-	lfu.stats.Hits++
+	lfu.stats.hit()
 	return *valPointer, true
 }
 
-// priority = alpha * exp(beta * cache accesses) + (key accesses)
+// priority = log(alpha * exp(beta * cache accesses) + (key accesses))
 func (lfu *ExpLFU) getExpPriority(accesses int) float64 {
-	exp := math.Exp(lfu.beta * float64(lfu.cacheAccesses))
-	return lfu.alpha*exp + float64(accesses)
+	logExp := math.Log(lfu.alpha) + lfu.beta*float64(lfu.cacheAccesses)
+	return logAddExp(logExp, math.Log(float64(accesses)))
+}
+
+// logAddExp returns log(exp(a) + exp(b)) without overflowing.
+func logAddExp(a float64, b float64) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	return a + math.Log1p(math.Exp(b-a))
 }
 
 // Remove removes and returns the value associated with the given key, if it exists.
@@ -101,6 +129,8 @@ func (lfu *ExpLFU) Remove(key string) (value []byte, ok bool) {
 		return nil, false
 	}
 
+	delete(lfu.pinned, key)
+
 	delete(lfu.lookup, key)
 
 	// remove matching element from priority queue
@@ -124,6 +154,11 @@ func (lfu *ExpLFU) Set(key string, value []byte) bool {
 		return false
 	}
 
+	// Pinned bindings can't be evicted to make room
+	if newElSize > lfu.maxSize-lfu.pinned.size(lfu.lookup, key) {
+		return false
+	}
+
 	// // print key value pair
 	// fmt.Printf("%s: %s\n", key, value)
 	// fmt.Println("------------------------------------------------------")
@@ -137,6 +172,12 @@ func (lfu *ExpLFU) Set(key string, value []byte) bool {
 		addedSize = len(value) - len(*existingVal)
 	}
 
+	// Take an updated binding out of the queue while making room, so that it
+	// can't be evicted to make room for itself
+	if existsInQ {
+		lfu.pq.Remove(lfu.items[key])
+	}
+
 	// Evict until there's enough room
 	for lfu.currSize+addedSize > lfu.maxSize {
 		EvictExpLFU(lfu)
@@ -150,18 +191,16 @@ func (lfu *ExpLFU) Set(key string, value []byte) bool {
 
 	// Add new key:value pair
 	if existsInQ {
-		existingItemPointer := lfu.items[key]
-		existingItem := *existingItemPointer
-		lfu.pq.Update(existingItemPointer, existingItem.priority+1)
-		existingItem.priority++
-		lfu.lookup[key] = existingVal
-		lfu.items[key] = &existingItem
+		item := lfu.items[key]
+		heap.Push(&lfu.pq, item)
+		lfu.pq.Update(item, logAddExp(item.priority, 0))
+		lfu.lookup[key] = &value
 		lfu.currSize += addedSize
 		// Only add to the queue if it doesn't exist yet
 	} else {
 		item := &Item{
 			key:      key,
-			priority: 1.0,
+			priority: 0, // log(1)
 			accesses: 1,
 		}
 
@@ -176,13 +215,17 @@ func (lfu *ExpLFU) Set(key string, value []byte) bool {
 
 // Evict the last element added to list
 func EvictExpLFU(lfu *ExpLFU) {
-	item := heap.Pop(&lfu.pq).(*Item)
+	item := lfu.pq.popUnless(func(item *Item) bool { return lfu.pinned.pinned(item.key) })
+	if item == nil {
+		return
+	}
 	// fmt.Printf("Evicting %s: %s. Accesses: %d\n", item.key, string(*lfu.lookup[item.key]), item.priority)
 	key := item.key
 	value := *(lfu.lookup[key])
 	delete(lfu.lookup, key)
 	delete(lfu.items, key)
 	lfu.currSize -= len(key) + len(value)
+	lfu.onEvict.evicted(key, value)
 }
 
 // Len returns the number of bindings in the ExpLFU.
@@ -194,3 +237,68 @@ func (lfu *ExpLFU) Len() int {
 func (lfu *ExpLFU) Stats() *Stats {
 	return lfu.stats
 }
+
+// OnEvict registers f to be called with each binding the ExpLFU evicts.
+func (lfu *ExpLFU) OnEvict(f EvictFunc) {
+	lfu.onEvict = append(lfu.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (lfu *ExpLFU) Peek(key string) (value []byte, ok bool) {
+	valPointer := lfu.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this ExpLFU, without
+// counting as a use or changing Stats.
+func (lfu *ExpLFU) Contains(key string) bool {
+	return lfu.lookup[key] != nil
+}
+
+// Range calls f with each binding in eviction order, lowest priority first,
+// until f returns false. f must not use the ExpLFU.
+func (lfu *ExpLFU) Range(f func(key string, value []byte) bool) {
+	for _, item := range lfu.pq.sorted() {
+		if !f(item.key, *lfu.lookup[item.key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (lfu *ExpLFU) Pin(key string) bool {
+	if lfu.lookup[key] == nil {
+		return false
+	}
+	lfu.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (lfu *ExpLFU) Unpin(key string) bool {
+	return lfu.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (lfu *ExpLFU) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(lfu, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (lfu *ExpLFU) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(lfu, keys, values, func() { EvictExpLFU(lfu) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (lfu *ExpLFU) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(lfu, keys)
+}
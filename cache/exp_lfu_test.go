@@ -10,6 +10,8 @@ package cache
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -511,3 +513,42 @@ func TestExpAllHits(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+// Over tens of millions of accesses priorities stay finite, so the binding
+// evicted is still a new one, if any, or else the least recently used.
+func TestExpLongRunOrder(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping long run in short mode")
+	}
+
+	lfu := NewExpLfu(128, 0.1, 0.5)
+	lastUse := map[string]int{} // 0 for bindings not used since they were added
+	lfu.OnEvict(func(key string, value []byte) {
+		for other := range lfu.items {
+			if lastUse[other] < lastUse[key] {
+				t.Errorf("After %d accesses evicted %s last used at %d, but %s was last used at %d",
+					lfu.cacheAccesses, key, lastUse[key], other, lastUse[other])
+				t.FailNow()
+			}
+		}
+		delete(lastUse, key)
+	})
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000000; i++ {
+		key := fmt.Sprintf("%04d", rng.Intn(64))
+		if _, found := lfu.Get(key); found {
+			lastUse[key] = lfu.cacheAccesses
+			continue
+		}
+		lfu.Set(key, []byte(key))
+		lastUse[key] = 0
+	}
+
+	for key, item := range lfu.items {
+		if math.IsInf(item.priority, 0) || math.IsNaN(item.priority) {
+			t.Errorf("Priority of %s is %f", key, item.priority)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"container/list"
+)
+
+// A FIFO is a fixed-size in-memory cache with first-in first-out eviction
+type FIFO struct {
+	lookup   map[string]*[]byte
+	nodes    map[string]*list.Element
+	q        *list.List // front is the oldest binding
+	maxSize  int
+	currSize int
+	stats    *Stats
+	onEvict  evictHandlers
+	pinned   pins
+}
+
+// NewFIFO returns a pointer to a new FIFO with a capacity to store limit bytes
+func NewFIFO(limit int) *FIFO {
+	cache := new(FIFO)
+	cache.lookup = map[string]*[]byte{}
+	cache.nodes = map[string]*list.Element{}
+	cache.q = list.New()
+	cache.maxSize = limit
+	cache.currSize = 0
+	cache.stats = new(Stats)
+	cache.pinned = pins{}
+	return cache
+}
+
+// MaxStorage returns the maximum number of bytes this FIFO can store
+func (fifo *FIFO) MaxStorage() int {
+	return fifo.maxSize
+}
+
+// RemainingStorage returns the number of unused bytes available in this FIFO
+func (fifo *FIFO) RemainingStorage() int {
+	return fifo.maxSize - fifo.currSize
+}
+
+// Resize changes the capacity of this FIFO to limit bytes, evicting bindings
+// in the usual order until they fit.
+func (fifo *FIFO) Resize(limit int) {
+	if pinned := fifo.pinned.size(fifo.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	fifo.maxSize = limit
+	for fifo.currSize > fifo.maxSize {
+		EvictFIFO(fifo)
+	}
+}
+
+// Get returns the value associated with the given key, if it exists.
+// Uses don't change the eviction order of a FIFO.
+// ok is true if a value was found and false otherwise.
+func (fifo *FIFO) Get(key string) (value []byte, ok bool) {
+	valPointer := fifo.lookup[key]
+
+	if valPointer == nil {
+		fifo.stats.miss()
+		return nil, false
+	}
+
+	fifo.stats.hit()
+	return *valPointer, true
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (fifo *FIFO) Remove(key string) (value []byte, ok bool) {
+	valPointer := fifo.lookup[key]
+
+	if valPointer == nil {
+		return nil, false
+	}
+
+	delete(fifo.pinned, key)
+
+	delete(fifo.lookup, key)
+	fifo.q.Remove(fifo.nodes[key])
+	delete(fifo.nodes, key)
+
+	fifo.currSize -= len(key) + len(*valPointer)
+	return *valPointer, true
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+func (fifo *FIFO) Set(key string, value []byte) bool {
+	// Check to see if too large for cache
+	newElSize := len(key) + len(value)
+	if newElSize > fifo.maxSize {
+		return false
+	}
+
+	// Pinned bindings can't be evicted to make room
+	if newElSize > fifo.maxSize-fifo.pinned.size(fifo.lookup, key) {
+		return false
+	}
+
+	// Updating a key replaces its value but keeps its place in the queue
+	if existingVal := fifo.lookup[key]; existingVal != nil {
+		addedSize := len(value) - len(*existingVal)
+		for fifo.currSize+addedSize > fifo.maxSize {
+			fifo.evict(fifo.nodes[key])
+		}
+		fifo.lookup[key] = &value
+		fifo.currSize += addedSize
+		return true
+	}
+
+	// Evict until there's enough room
+	for fifo.currSize+newElSize > fifo.maxSize {
+		EvictFIFO(fifo)
+	}
+
+	fifo.nodes[key] = fifo.q.PushBack(key)
+	fifo.lookup[key] = &value
+	fifo.currSize += newElSize
+	return true
+}
+
+// evict removes the oldest binding in the queue other than keep that isn't
+// pinned.
+func (fifo *FIFO) evict(keep *list.Element) {
+	victim := fifo.q.Front()
+	for victim != nil && (victim == keep || fifo.pinned.pinned(victim.Value.(string))) {
+		victim = victim.Next()
+	}
+	if victim == nil {
+		return
+	}
+	key := fifo.q.Remove(victim).(string)
+	value := *(fifo.lookup[key])
+	delete(fifo.lookup, key)
+	delete(fifo.nodes, key)
+	fifo.currSize -= len(key) + len(value)
+	fifo.onEvict.evicted(key, value)
+}
+
+// Evict the oldest binding in the queue
+func EvictFIFO(fifo *FIFO) {
+	fifo.evict(nil)
+}
+
+// Len returns the number of bindings in the FIFO.
+func (fifo *FIFO) Len() int {
+	return fifo.q.Len()
+}
+
+// Stats returns statistics about how many search hits and misses have occurred.
+func (fifo *FIFO) Stats() *Stats {
+	return fifo.stats
+}
+
+// OnEvict registers f to be called with each binding the FIFO evicts.
+func (fifo *FIFO) OnEvict(f EvictFunc) {
+	fifo.onEvict = append(fifo.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (fifo *FIFO) Peek(key string) (value []byte, ok bool) {
+	valPointer := fifo.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this FIFO, without
+// counting as a use or changing Stats.
+func (fifo *FIFO) Contains(key string) bool {
+	return fifo.lookup[key] != nil
+}
+
+// Range calls f with each binding in eviction order, oldest first, until f
+// returns false. f must not use the FIFO.
+func (fifo *FIFO) Range(f func(key string, value []byte) bool) {
+	for el := fifo.q.Front(); el != nil; el = el.Next() {
+		key := el.Value.(string)
+		if !f(key, *fifo.lookup[key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (fifo *FIFO) Pin(key string) bool {
+	if fifo.lookup[key] == nil {
+		return false
+	}
+	fifo.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (fifo *FIFO) Unpin(key string) bool {
+	return fifo.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (fifo *FIFO) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(fifo, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (fifo *FIFO) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(fifo, keys, values, func() { EvictFIFO(fifo) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (fifo *FIFO) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(fifo, keys)
+}
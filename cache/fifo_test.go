@@ -0,0 +1,107 @@
+/******************************************************************************
+ * fifo_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for fifo.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestFIFOSetGet(t *testing.T) {
+	capacity := 64
+	fifo := NewFIFO(capacity)
+	checkCapacity(t, fifo, capacity)
+
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("key%d", i)
+		val := []byte(key)
+		ok := fifo.Set(key, val)
+		if !ok {
+			t.Errorf("Failed to add binding with key: %s", key)
+			t.FailNow()
+		}
+
+		res, _ := fifo.Get(key)
+		if !bytesEqual(res, val) {
+			t.Errorf("Wrong value %s for binding with key: %s", res, key)
+			t.FailNow()
+		}
+	}
+}
+
+func TestFIFORemove(t *testing.T) {
+	capacity := 64
+	fifo := NewFIFO(capacity)
+
+	key := "Hello"
+	val := []byte("World")
+	fifo.Set(key, val)
+
+	res, ok := fifo.Remove(key)
+	if !ok || !bytesEqual(res, val) {
+		t.Errorf("Failed to remove binding with key: %s", key)
+		t.FailNow()
+	}
+	if fifo.Len() != 0 || fifo.RemainingStorage() != capacity {
+		t.Errorf("FIFO should be empty but has length %d", fifo.Len())
+		t.FailNow()
+	}
+}
+
+func TestFIFOTooLarge(t *testing.T) {
+	capacity := 10
+	fifo := NewFIFO(capacity)
+
+	key := "123456"
+	ok := fifo.Set(key, []byte(key))
+	if ok {
+		t.Errorf("Should have failed to add binding with key: %s", key)
+		t.FailNow()
+	}
+}
+
+// The oldest binding is evicted no matter how often it was used, and updating
+// a binding doesn't move it to the back of the queue.
+func TestFIFOEvict(t *testing.T) {
+	capacity := 30
+	fifo := NewFIFO(capacity)
+
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("____%d", i)
+		fifo.Set(key, []byte(key))
+	}
+	fifo.Get("____0")
+	fifo.Set("____0", []byte("___00"))
+
+	fifo.Set("____3", []byte("____3"))
+	if _, found := fifo.Get("____0"); found {
+		t.Errorf("Should have evicted binding with key: ____0")
+		t.FailNow()
+	}
+
+	// growing ____2 evicts ____1 but never ____2 itself
+	fifo.Set("____2", []byte("____2____2"))
+	if _, found := fifo.Get("____1"); found {
+		t.Errorf("Should have evicted binding with key: ____1")
+		t.FailNow()
+	}
+	res, _ := fifo.Get("____2")
+	if !bytesEqual(res, []byte("____2____2")) {
+		t.Errorf("Wrong value %s for binding with key: ____2", res)
+		t.FailNow()
+	}
+	if fifo.RemainingStorage() != 5 {
+		t.Errorf("Remaining storage should be 5 but is %d", fifo.RemainingStorage())
+		t.FailNow()
+	}
+}
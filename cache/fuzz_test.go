@@ -0,0 +1,152 @@
+/******************************************************************************
+ * fuzz_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -fuzz=FuzzPolicies`
+ * Description:
+ *    Fuzz tests that run random sequences of operations against every policy
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// FuzzPolicies reads its input as a capacity byte followed by operations of
+// three bytes each: the operation, the key and the value size. Every policy
+// runs the operations and is checked after each one.
+func FuzzPolicies(f *testing.F) {
+	f.Add([]byte{64, 1, 0, 10, 1, 1, 10, 0, 0, 0, 3, 1, 0})
+	f.Add([]byte{32, 1, 0, 20, 1, 0, 28, 1, 33, 4, 2, 0, 30, 0, 33, 0})
+	f.Add([]byte{200, 1, 1, 50, 1, 2, 50, 1, 3, 50, 1, 1, 90, 0, 2, 0, 1, 4, 150})
+	f.Add([]byte{0, 1, 0, 0, 1, 32, 0, 0, 0, 0, 3, 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		capacity := int(data[0])
+		for _, policy := range experimentPolicies {
+			runFuzzOps(t, policy.name, policy.factory(capacity), data[1:])
+		}
+	})
+}
+
+// runFuzzOps decodes and applies ops to cache, failing t if cache returns a
+// value other than the last one set or its bookkeeping is inconsistent.
+func runFuzzOps(t *testing.T, policy string, cache Cache, ops []byte) {
+	bindings := map[string][]byte{}
+	for i := 0; i+2 < len(ops); i += 3 {
+		key := fuzzKey(ops[i+1])
+		var op string
+		switch ops[i] % 4 {
+		case 0:
+			op = fmt.Sprintf("Get(%q)", key)
+			value, ok := cache.Get(key)
+			if ok && !bytesEqual(value, bindings[key]) {
+				t.Errorf("%s: %s returned %q, but the last value set was %q", policy, op, value, bindings[key])
+				t.FailNow()
+			}
+		case 1, 2:
+			value := []byte(strings.Repeat(string(rune('a'+i%26)), int(ops[i+2])))
+			op = fmt.Sprintf("Set(%q, %d bytes)", key, len(value))
+			ok := cache.Set(key, value)
+			if ok != (len(key)+len(value) <= cache.MaxStorage()) {
+				t.Errorf("%s: %s returned %t with MaxStorage %d", policy, op, ok, cache.MaxStorage())
+				t.FailNow()
+			}
+			if ok {
+				bindings[key] = value
+			}
+		case 3:
+			op = fmt.Sprintf("Remove(%q)", key)
+			value, ok := cache.Remove(key)
+			if ok && !bytesEqual(value, bindings[key]) {
+				t.Errorf("%s: %s returned %q, but the last value set was %q", policy, op, value, bindings[key])
+				t.FailNow()
+			}
+		}
+		checkInvariants(t, fmt.Sprintf("%s after op %d, %s", policy, i/3, op), cache)
+	}
+}
+
+// fuzzKey returns a key of 1 to 8 bytes for b, one of 32 keys of each length.
+func fuzzKey(b byte) string {
+	return strings.Repeat("k", int(b/32)) + string(rune('A'+b%32))
+}
+
+// Fails test t if the storage cache uses isn't the size of the bindings in
+// its lookup table, or if its priority queue, if it has one, is out of order.
+func checkInvariants(t *testing.T, context string, cache Cache) {
+	var lookup map[string]*[]byte
+	var currSize int
+	var pq PriorityQueue
+	switch c := cache.(type) {
+	case *LRU:
+		lookup, currSize = c.lookup, c.currSize
+	case *LFU:
+		lookup, currSize, pq = c.lookup, c.currSize, c.pq
+	case *LogLFU:
+		lookup, currSize, pq = c.lookup, c.currSize, c.pq
+	case *LinearLFU:
+		lookup, currSize, pq = c.lookup, c.currSize, c.pq
+	case *ExpLFU:
+		lookup, currSize, pq = c.lookup, c.currSize, c.pq
+	case *LFUDA:
+		lookup, currSize, pq = c.lookup, c.currSize, c.pq
+	case *LIRS:
+		lookup, currSize = c.lookup, c.currSize
+	case *GDSF:
+		lookup, currSize, pq = c.lookup, c.currSize, c.pq
+	case *LRUK:
+		lookup, currSize, pq = c.lookup, c.currSize, c.pq
+	case *LeCaR:
+		lookup, currSize, pq = c.lookup, c.currSize, c.pq
+	case *FIFO:
+		lookup, currSize = c.lookup, c.currSize
+	case *Random:
+		lookup, currSize = c.lookup, c.currSize
+	default:
+		t.Errorf("%s: no invariants known for %s", context, cacheType(cache))
+		t.FailNow()
+	}
+
+	size := 0
+	for key, value := range lookup {
+		size += len(key) + len(*value)
+	}
+	if size != currSize || currSize > cache.MaxStorage() {
+		t.Errorf("%s: bindings take %d bytes, but currSize is %d of %d", context, size, currSize, cache.MaxStorage())
+		t.FailNow()
+	}
+	if cache.Len() != len(lookup) || cache.RemainingStorage() != cache.MaxStorage()-currSize {
+		t.Errorf("%s: Len is %d and RemainingStorage %d with %d bindings of %d bytes",
+			context, cache.Len(), cache.RemainingStorage(), len(lookup), size)
+		t.FailNow()
+	}
+
+	if pq == nil {
+		return
+	}
+	if pq.Len() != len(lookup) {
+		t.Errorf("%s: priority queue has %d items for %d bindings", context, pq.Len(), len(lookup))
+		t.FailNow()
+	}
+	for i, item := range pq {
+		if item.index != i || lookup[item.key] == nil {
+			t.Errorf("%s: item %d for key %q has index %d", context, i, item.key, item.index)
+			t.FailNow()
+		}
+		if parent := (i - 1) / 2; i > 0 && pq.Less(i, parent) {
+			t.Errorf("%s: item %d has priority %f, less than its parent's %f",
+				context, i, item.priority, pq[parent].priority)
+			t.FailNow()
+		}
+	}
+}
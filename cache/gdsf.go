@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// A CostFunc returns the cost of fetching the binding for key, which takes up
+// size bytes, on a miss.
+type CostFunc func(key string, size int) float64
+
+// UniformCost treats every miss as equally expensive, so GDSF maximizes the
+// object hit rate by favouring small bindings.
+func UniformCost(key string, size int) float64 {
+	return 1.0
+}
+
+// ByteCost makes the cost of a miss proportional to its size, so GDSF
+// maximizes the byte hit rate and behaves like LFU with aging.
+func ByteCost(key string, size int) float64 {
+	return float64(size)
+}
+
+// LatencyCost returns a CostFunc that uses the time latency reports for
+// fetching key, in milliseconds, as its cost.
+func LatencyCost(latency func(key string) time.Duration) CostFunc {
+	return func(key string, size int) float64 {
+		return float64(latency(key)) / float64(time.Millisecond)
+	}
+}
+
+// A GDSF is a fixed-size in-memory cache with GreedyDual-Size-Frequency
+// eviction. Each binding has priority L + frequency * cost / size, where L is
+// the priority of the last evicted binding, so large, cheap or rarely used
+// bindings are evicted first and long-idle bindings age out as L grows.
+type GDSF struct {
+	pq       PriorityQueue
+	lookup   map[string]*[]byte
+	items    map[string]*Item
+	maxSize  int
+	currSize int
+	stats    *Stats
+	onEvict  evictHandlers
+	pinned   pins
+
+	cost      CostFunc
+	inflation float64
+}
+
+// NewGDSF returns a pointer to a new GDSF with a capacity to store limit bytes
+// that prices misses with cost.
+func NewGDSF(limit int, cost CostFunc) *GDSF {
+	cache := new(GDSF)
+
+	cache.lookup = map[string]*[]byte{}
+	cache.items = map[string]*Item{}
+
+	cache.pq = make(PriorityQueue, 0)
+	heap.Init(&cache.pq)
+
+	cache.maxSize = limit
+	cache.currSize = 0
+	cache.stats = new(Stats)
+
+	cache.cost = cost
+	cache.inflation = 0
+	cache.pinned = pins{}
+	return cache
+}
+
+// MaxStorage returns the maximum number of bytes this GDSF can store
+func (gdsf *GDSF) MaxStorage() int {
+	return gdsf.maxSize
+}
+
+// RemainingStorage returns the number of unused bytes available in this GDSF
+func (gdsf *GDSF) RemainingStorage() int {
+	return gdsf.maxSize - gdsf.currSize
+}
+
+// Resize changes the capacity of this GDSF to limit bytes, evicting bindings
+// in the usual order until they fit.
+func (gdsf *GDSF) Resize(limit int) {
+	if pinned := gdsf.pinned.size(gdsf.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	gdsf.maxSize = limit
+	for gdsf.currSize > gdsf.maxSize {
+		EvictGDSF(gdsf)
+	}
+}
+
+// Inflation returns the current inflation value L, the priority of the most
+// recently evicted binding.
+func (gdsf *GDSF) Inflation() float64 {
+	return gdsf.inflation
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (gdsf *GDSF) Get(key string) (value []byte, ok bool) {
+	valPointer := gdsf.lookup[key]
+
+	if valPointer == nil {
+		gdsf.stats.miss()
+		return nil, false
+	}
+
+	item := gdsf.items[key]
+	item.accesses++
+	size := len(key) + len(*valPointer)
+	gdsf.pq.Update(item, gdsf.getGDSFPriority(key, size, item.accesses))
+
+	gdsf.stats.hit()
+	gdsf.stats.ByteHits += size
+	return *valPointer, true
+}
+
+// priority = L + frequency * cost / size
+func (gdsf *GDSF) getGDSFPriority(key string, size int, accesses int) float64 {
+	// Empty bindings are priced as if they took up a single byte
+	if size < 1 {
+		size = 1
+	}
+	return gdsf.inflation + float64(accesses)*gdsf.cost(key, size)/float64(size)
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (gdsf *GDSF) Remove(key string) (value []byte, ok bool) {
+	valPointer := gdsf.lookup[key]
+
+	if valPointer == nil {
+		return nil, false
+	}
+
+	delete(gdsf.pinned, key)
+
+	delete(gdsf.lookup, key)
+
+	// remove matching element from priority queue
+	gdsf.pq.Remove(gdsf.items[key])
+	delete(gdsf.items, key)
+
+	gdsf.currSize -= len(key) + len(*valPointer)
+	return *valPointer, true
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+func (gdsf *GDSF) Set(key string, value []byte) bool {
+	// Check to see if too large for cache
+	newElSize := len(key) + len(value)
+	if newElSize > gdsf.maxSize {
+		return false
+	}
+
+	// Pinned bindings can't be evicted to make room
+	if newElSize > gdsf.maxSize-gdsf.pinned.size(gdsf.lookup, key) {
+		return false
+	}
+
+	// Updating a key replaces its value and counts as a use
+	if existingVal := gdsf.lookup[key]; existingVal != nil {
+		gdsf.currSize += newElSize - (len(key) + len(*existingVal))
+		gdsf.lookup[key] = &value
+
+		item := gdsf.items[key]
+		item.accesses++
+		gdsf.pq.Update(item, gdsf.getGDSFPriority(key, newElSize, item.accesses))
+
+		// Evict until there's enough room, setting the updated binding aside
+		// so that it is never evicted
+		if gdsf.currSize > gdsf.maxSize {
+			gdsf.pq.Remove(item)
+			for gdsf.currSize > gdsf.maxSize {
+				EvictGDSF(gdsf)
+			}
+			heap.Push(&gdsf.pq, item)
+		}
+		return true
+	}
+
+	// Evict until there's enough room
+	for gdsf.currSize+newElSize > gdsf.maxSize {
+		EvictGDSF(gdsf)
+	}
+
+	item := &Item{
+		key:      key,
+		accesses: 1,
+	}
+	item.priority = gdsf.getGDSFPriority(key, newElSize, item.accesses)
+
+	heap.Push(&gdsf.pq, item)
+	gdsf.lookup[key] = &value
+	gdsf.items[key] = item
+	gdsf.currSize += newElSize
+	gdsf.stats.ByteMisses += newElSize
+
+	return true
+}
+
+// Evict the binding with the lowest priority and raise the inflation value
+// to its priority
+func EvictGDSF(gdsf *GDSF) {
+	item := gdsf.pq.popUnless(func(item *Item) bool { return gdsf.pinned.pinned(item.key) })
+	if item == nil {
+		return
+	}
+	key := item.key
+	value := *(gdsf.lookup[key])
+	delete(gdsf.lookup, key)
+	delete(gdsf.items, key)
+	gdsf.currSize -= len(key) + len(value)
+	gdsf.inflation = item.priority
+	gdsf.onEvict.evicted(key, value)
+}
+
+// Len returns the number of bindings in the GDSF.
+func (gdsf *GDSF) Len() int {
+	return gdsf.pq.Len()
+}
+
+// Stats returns statistics about how many search hits and misses have occurred.
+func (gdsf *GDSF) Stats() *Stats {
+	return gdsf.stats
+}
+
+// OnEvict registers f to be called with each binding the GDSF evicts.
+func (gdsf *GDSF) OnEvict(f EvictFunc) {
+	gdsf.onEvict = append(gdsf.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (gdsf *GDSF) Peek(key string) (value []byte, ok bool) {
+	valPointer := gdsf.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this GDSF, without
+// counting as a use or changing Stats.
+func (gdsf *GDSF) Contains(key string) bool {
+	return gdsf.lookup[key] != nil
+}
+
+// Range calls f with each binding in eviction order, lowest priority first,
+// until f returns false. f must not use the GDSF.
+func (gdsf *GDSF) Range(f func(key string, value []byte) bool) {
+	for _, item := range gdsf.pq.sorted() {
+		if !f(item.key, *gdsf.lookup[item.key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (gdsf *GDSF) Pin(key string) bool {
+	if gdsf.lookup[key] == nil {
+		return false
+	}
+	gdsf.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (gdsf *GDSF) Unpin(key string) bool {
+	return gdsf.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (gdsf *GDSF) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(gdsf, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (gdsf *GDSF) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(gdsf, keys, values, func() { EvictGDSF(gdsf) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (gdsf *GDSF) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(gdsf, keys)
+}
@@ -0,0 +1,179 @@
+/******************************************************************************
+ * gdsf_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for gdsf.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestGDSFSetGet(t *testing.T) {
+	capacity := 64
+	gdsf := NewGDSF(capacity, UniformCost)
+	checkCapacity(t, gdsf, capacity)
+
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("key%d", i)
+		val := []byte(key)
+		ok := gdsf.Set(key, val)
+		if !ok {
+			t.Errorf("Failed to add binding with key: %s", key)
+			t.FailNow()
+		}
+
+		res, _ := gdsf.Get(key)
+		if !bytesEqual(res, val) {
+			t.Errorf("Wrong value %s for binding with key: %s", res, key)
+			t.FailNow()
+		}
+	}
+
+	// updating a key replaces its value
+	key := "key1"
+	val := []byte("a new value")
+	gdsf.Set(key, val)
+	res, _ := gdsf.Get(key)
+	if !bytesEqual(res, val) {
+		t.Errorf("Wrong value %s for binding with key: %s", res, key)
+		t.FailNow()
+	}
+	if gdsf.RemainingStorage() != capacity-3*8-len(key)-len(val) {
+		t.Errorf("Wrong remaining storage %d after update", gdsf.RemainingStorage())
+		t.FailNow()
+	}
+}
+
+func TestGDSFRemove(t *testing.T) {
+	capacity := 64
+	gdsf := NewGDSF(capacity, UniformCost)
+
+	key := "Hello"
+	val := []byte("World")
+	gdsf.Set(key, val)
+
+	res, ok := gdsf.Remove(key)
+	if !ok || !bytesEqual(res, val) {
+		t.Errorf("Failed to remove binding with key: %s", key)
+		t.FailNow()
+	}
+	if gdsf.Len() != 0 || gdsf.RemainingStorage() != capacity {
+		t.Errorf("GDSF should be empty but has length %d", gdsf.Len())
+		t.FailNow()
+	}
+}
+
+func TestGDSFTooLarge(t *testing.T) {
+	capacity := 10
+	gdsf := NewGDSF(capacity, UniformCost)
+
+	key := "123456"
+	ok := gdsf.Set(key, []byte(key))
+	if ok {
+		t.Errorf("Should have failed to add binding with key: %s", key)
+		t.FailNow()
+	}
+}
+
+// With uniform cost a large binding is evicted before small ones, even if
+// it is used as often.
+func TestGDSFEvictsLarge(t *testing.T) {
+	capacity := 100
+	gdsf := NewGDSF(capacity, UniformCost)
+
+	gdsf.Set("big", make([]byte, 47))
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("small%d", i)
+		gdsf.Set(key, []byte("12345"))
+	}
+
+	// 50 + 5 * 11 bytes doesn't fit, so something had to go
+	if _, found := gdsf.Get("big"); found {
+		t.Errorf("Should have evicted the large binding")
+		t.FailNow()
+	}
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("small%d", i)
+		if _, found := gdsf.Get(key); !found {
+			t.Errorf("Could not find binding with key: %s", key)
+			t.FailNow()
+		}
+	}
+	if gdsf.Inflation() == 0 {
+		t.Errorf("Evicting should raise the inflation value")
+		t.FailNow()
+	}
+}
+
+// With byte cost size cancels out and GDSF evicts by frequency.
+func TestGDSFByteCost(t *testing.T) {
+	capacity := 100
+	gdsf := NewGDSF(capacity, ByteCost)
+
+	gdsf.Set("big", make([]byte, 47))
+	gdsf.Get("big")
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("small%d", i)
+		gdsf.Set(key, []byte("12345"))
+	}
+
+	if _, found := gdsf.Get("big"); !found {
+		t.Errorf("Should have kept the frequently used large binding")
+		t.FailNow()
+	}
+}
+
+func TestGDSFLatencyCost(t *testing.T) {
+	capacity := 25
+	slow := func(key string) time.Duration {
+		if key == "slow" {
+			return 100 * time.Millisecond
+		}
+		return time.Millisecond
+	}
+	gdsf := NewGDSF(capacity, LatencyCost(slow))
+
+	gdsf.Set("slow", []byte("123456"))
+	gdsf.Set("fast", []byte("123456"))
+	gdsf.Set("next", []byte("123456"))
+
+	if _, found := gdsf.Get("slow"); !found {
+		t.Errorf("Should have kept the expensive binding")
+		t.FailNow()
+	}
+	if _, found := gdsf.Get("fast"); found {
+		t.Errorf("Should have evicted the cheap binding")
+		t.FailNow()
+	}
+}
+
+func TestGDSFByteStats(t *testing.T) {
+	capacity := 100
+	gdsf := NewGDSF(capacity, UniformCost)
+
+	if _, found := gdsf.Get("key"); !found {
+		gdsf.Set("key", []byte("value"))
+	}
+	gdsf.Get("key")
+	gdsf.Get("key")
+
+	stats := gdsf.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Incorrect cache stats.\n Cache Hits: %d\n Cache Misses: %d\n", stats.Hits, stats.Misses)
+		t.FailNow()
+	}
+	if stats.ByteHits != 16 || stats.ByteMisses != 8 {
+		t.Errorf("Incorrect byte stats.\n Byte Hits: %d\n Byte Misses: %d\n", stats.ByteHits, stats.ByteMisses)
+		t.FailNow()
+	}
+}
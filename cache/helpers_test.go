@@ -14,6 +14,18 @@ func cacheType(cache Cache) string {
 	switch cache.(type) {
 	case *LFU:
 		return "LFU"
+	case *LIRS:
+		return "LIRS"
+	case *GDSF:
+		return "GDSF"
+	case *LRUK:
+		return "LRU-K"
+	case *LeCaR:
+		return "LeCaR"
+	case *FIFO:
+		return "FIFO"
+	case *Random:
+		return "Random"
 	default:
 		return "cache"
 	}
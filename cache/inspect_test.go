@@ -0,0 +1,126 @@
+/******************************************************************************
+ * inspect_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    A testing suite for Peek, Contains and Range on every policy
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// Peeking at every key before each operation doesn't change what a cache
+// evicts or its Stats.
+func TestPeekDoesNotUse(t *testing.T) {
+	for _, policy := range experimentPolicies {
+		plain := policy.factory(128).(Inspector)
+		peeked := policy.factory(128).(Inspector)
+		plainEvicted := recordEvictions(plain)
+		peekedEvicted := recordEvictions(peeked)
+
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("%04d", rng.Intn(40))
+			for j := 0; j < 40; j++ {
+				other := fmt.Sprintf("%04d", j)
+				value, ok := peeked.Peek(other)
+				if ok != peeked.Contains(other) || (ok && !bytesEqual(value, []byte(other))) {
+					t.Errorf("%s: Peek(%q) returned %q, %t but Contains returned %t",
+						policy.name, other, value, ok, peeked.Contains(other))
+					t.FailNow()
+				}
+			}
+			Keys(peeked)
+
+			for _, cache := range []Inspector{plain, peeked} {
+				if _, ok := cache.Get(key); !ok {
+					cache.Set(key, []byte(key))
+				}
+			}
+		}
+
+		if !plain.Stats().Equals(peeked.Stats()) || fmt.Sprint(*plainEvicted) != fmt.Sprint(*peekedEvicted) {
+			t.Errorf("%s: peeking changed Stats from %v to %v or evictions", policy.name, *plain.Stats(), *peeked.Stats())
+			t.FailNow()
+		}
+		if fmt.Sprint(Keys(plain)) != fmt.Sprint(Keys(peeked)) {
+			t.Errorf("%s: peeking changed the eviction order from %v to %v", policy.name, Keys(plain), Keys(peeked))
+			t.FailNow()
+		}
+	}
+}
+
+// The first key Range returns is the next one evicted, for policies that
+// don't evict at random.
+func TestRangeEvictionOrder(t *testing.T) {
+	for _, policy := range experimentPolicies {
+		if policy.name == "LeCaR" || policy.name == "Random" {
+			continue
+		}
+		cache := policy.factory(128).(Inspector)
+		evicted := recordEvictions(cache)
+
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 5000; i++ {
+			key := fmt.Sprintf("%04d", rng.Intn(40))
+			if _, ok := cache.Get(key); ok {
+				continue
+			}
+
+			keys := Keys(cache)
+			if len(keys) != cache.Len() {
+				t.Errorf("%s: Range returned %d keys, but Len is %d", policy.name, len(keys), cache.Len())
+				t.FailNow()
+			}
+			*evicted = nil
+			cache.Set(key, []byte(key))
+			if len(*evicted) > 0 && (*evicted)[0] != keys[0] {
+				t.Errorf("%s: evicted %s, but Range returned %v", policy.name, (*evicted)[0], keys)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Range stops as soon as f returns false.
+func TestRangeStops(t *testing.T) {
+	for _, policy := range experimentPolicies {
+		cache := policy.factory(128).(Inspector)
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprintf("%04d", i)
+			cache.Set(key, []byte(key))
+		}
+
+		calls := 0
+		cache.Range(func(key string, value []byte) bool {
+			calls++
+			return calls < 3
+		})
+		if calls != 3 {
+			t.Errorf("%s: Range called f %d times after it returned false on the 3rd", policy.name, calls)
+			t.FailNow()
+		}
+	}
+}
+
+/******************************************************************************/
+/*                                 Helpers                                    */
+/******************************************************************************/
+
+// recordEvictions returns the keys cache evicts, in order.
+func recordEvictions(cache Cache) *[]string {
+	evicted := &[]string{}
+	cache.(Evicter).OnEvict(func(key string, value []byte) {
+		*evicted = append(*evicted, key)
+	})
+	return evicted
+}
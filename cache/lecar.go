@@ -0,0 +1,393 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"math"
+	"math/rand"
+)
+
+// lecarGhost remembers a key LeCaR evicted, when, and how often it was used.
+type lecarGhost struct {
+	key      string
+	evicted  int
+	accesses int
+}
+
+// lecarHistory is a bounded FIFO of keys evicted by one expert policy.
+type lecarHistory struct {
+	ghosts *list.List // front is the oldest
+	lookup map[string]*list.Element
+}
+
+func newLecarHistory() *lecarHistory {
+	return &lecarHistory{
+		ghosts: list.New(),
+		lookup: map[string]*list.Element{},
+	}
+}
+
+// add remembers an evicted key, forgetting the oldest ones beyond limit.
+func (h *lecarHistory) add(ghost *lecarGhost, limit int) {
+	h.lookup[ghost.key] = h.ghosts.PushBack(ghost)
+	for h.ghosts.Len() > limit {
+		oldest := h.ghosts.Remove(h.ghosts.Front()).(*lecarGhost)
+		delete(h.lookup, oldest.key)
+	}
+}
+
+// take forgets and returns the ghost for key, or nil if there is none.
+func (h *lecarHistory) take(key string) *lecarGhost {
+	el := h.lookup[key]
+	if el == nil {
+		return nil
+	}
+	delete(h.lookup, key)
+	return h.ghosts.Remove(el).(*lecarGhost)
+}
+
+// A LeCaR is a fixed-size in-memory cache that learns how to mix LRU and LFU
+// eviction. It keeps both orderings over the same bindings and evicts the
+// victim of one of them, chosen at random by weight. Keys evicted by each
+// expert are remembered, and a miss on one of them is regret that shifts
+// weight towards the other expert.
+type LeCaR struct {
+	lookup   map[string]*[]byte
+	nodes    map[string]*list.Element
+	q        *list.List // LRU order, front is the most recent
+	items    map[string]*Item
+	pq       PriorityQueue // LFU order
+	maxSize  int
+	currSize int
+	stats    *Stats
+	onEvict  evictHandlers
+	pinned   pins
+
+	lruHistory    *lecarHistory
+	lfuHistory    *lecarHistory
+	learningRate  float64
+	discountRate  float64
+	weightLRU     float64
+	weightLFU     float64
+	rand          *rand.Rand
+	cacheAccesses int
+}
+
+// NewLeCaR returns a pointer to a new LeCaR with a capacity to store limit
+// bytes. learningRate scales how far each regret moves the weights, and
+// regret for a key decays by discountRate for every access since its
+// eviction. seed seeds the choice between experts.
+func NewLeCaR(limit int, learningRate float64, discountRate float64, seed int64) *LeCaR {
+	cache := new(LeCaR)
+	cache.lookup = map[string]*[]byte{}
+	cache.nodes = map[string]*list.Element{}
+	cache.q = list.New()
+	cache.items = map[string]*Item{}
+
+	cache.pq = make(PriorityQueue, 0)
+	heap.Init(&cache.pq)
+
+	cache.maxSize = limit
+	cache.currSize = 0
+	cache.stats = new(Stats)
+
+	cache.lruHistory = newLecarHistory()
+	cache.lfuHistory = newLecarHistory()
+	cache.learningRate = learningRate
+	cache.discountRate = discountRate
+	cache.weightLRU = 0.5
+	cache.weightLFU = 0.5
+	cache.rand = rand.New(rand.NewSource(seed))
+	cache.cacheAccesses = 0
+	cache.stats.Weights = map[string]float64{
+		"LRU": cache.weightLRU,
+		"LFU": cache.weightLFU,
+	}
+	cache.pinned = pins{}
+	return cache
+}
+
+// MaxStorage returns the maximum number of bytes this LeCaR can store
+func (lecar *LeCaR) MaxStorage() int {
+	return lecar.maxSize
+}
+
+// RemainingStorage returns the number of unused bytes available in this LeCaR
+func (lecar *LeCaR) RemainingStorage() int {
+	return lecar.maxSize - lecar.currSize
+}
+
+// Resize changes the capacity of this LeCaR to limit bytes, evicting bindings
+// in the usual order until they fit.
+func (lecar *LeCaR) Resize(limit int) {
+	if pinned := lecar.pinned.size(lecar.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	lecar.maxSize = limit
+	for lecar.currSize > lecar.maxSize {
+		EvictLeCaR(lecar)
+	}
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (lecar *LeCaR) Get(key string) (value []byte, ok bool) {
+	lecar.cacheAccesses++
+	valPointer := lecar.lookup[key]
+
+	if valPointer == nil {
+		lecar.stats.miss()
+		return nil, false
+	}
+
+	lecar.use(key)
+
+	lecar.stats.hit()
+	return *valPointer, true
+}
+
+// use moves key to the front of the LRU order and bumps its frequency.
+func (lecar *LeCaR) use(key string) {
+	lecar.q.MoveToFront(lecar.nodes[key])
+	item := lecar.items[key]
+	item.accesses++
+	lecar.pq.Update(item, float64(item.accesses))
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (lecar *LeCaR) Remove(key string) (value []byte, ok bool) {
+	valPointer := lecar.lookup[key]
+
+	if valPointer == nil {
+		return nil, false
+	}
+
+	delete(lecar.pinned, key)
+
+	lecar.drop(key)
+	return *valPointer, true
+}
+
+// drop removes key from the lookup table and both orderings.
+func (lecar *LeCaR) drop(key string) {
+	lecar.currSize -= len(key) + len(*lecar.lookup[key])
+	delete(lecar.lookup, key)
+
+	lecar.q.Remove(lecar.nodes[key])
+	delete(lecar.nodes, key)
+
+	lecar.pq.Remove(lecar.items[key])
+	delete(lecar.items, key)
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+func (lecar *LeCaR) Set(key string, value []byte) bool {
+	lecar.cacheAccesses++
+
+	// Check to see if too large for cache
+	newElSize := len(key) + len(value)
+	if newElSize > lecar.maxSize {
+		return false
+	}
+
+	// Pinned bindings can't be evicted to make room
+	if newElSize > lecar.maxSize-lecar.pinned.size(lecar.lookup, key) {
+		return false
+	}
+
+	// Updating a key replaces its value and counts as a use
+	if existingVal := lecar.lookup[key]; existingVal != nil {
+		lecar.currSize += newElSize - (len(key) + len(*existingVal))
+		lecar.lookup[key] = &value
+		lecar.use(key)
+
+		// Evict until there's enough room, never evicting the updated key
+		for lecar.currSize > lecar.maxSize {
+			lecar.evict(lecar.items[key])
+		}
+		return true
+	}
+
+	// A miss on a key one expert evicted is regret for that expert
+	accesses := 1
+	if ghost := lecar.lruHistory.take(key); ghost != nil {
+		lecar.weightLFU *= lecar.getRegretFactor(ghost)
+		lecar.normalizeWeights()
+		accesses += ghost.accesses
+	} else if ghost := lecar.lfuHistory.take(key); ghost != nil {
+		lecar.weightLRU *= lecar.getRegretFactor(ghost)
+		lecar.normalizeWeights()
+		accesses += ghost.accesses
+	}
+
+	// Evict until there's enough room
+	for lecar.currSize+newElSize > lecar.maxSize {
+		EvictLeCaR(lecar)
+	}
+
+	item := &Item{
+		key:      key,
+		priority: float64(accesses),
+		accesses: accesses,
+	}
+	heap.Push(&lecar.pq, item)
+	lecar.items[key] = item
+	lecar.nodes[key] = lecar.q.PushFront(key)
+	lecar.lookup[key] = &value
+	lecar.currSize += newElSize
+
+	return true
+}
+
+// factor = exp(learning rate * discount rate ^ (accesses since eviction))
+func (lecar *LeCaR) getRegretFactor(ghost *lecarGhost) float64 {
+	regret := math.Pow(lecar.discountRate, float64(lecar.cacheAccesses-ghost.evicted))
+	return math.Exp(lecar.learningRate * regret)
+}
+
+// normalizeWeights rescales the expert weights to sum to one and publishes
+// them in Stats.
+func (lecar *LeCaR) normalizeWeights() {
+	total := lecar.weightLRU + lecar.weightLFU
+	lecar.weightLRU /= total
+	lecar.weightLFU /= total
+	lecar.stats.Weights["LRU"] = lecar.weightLRU
+	lecar.stats.Weights["LFU"] = lecar.weightLFU
+}
+
+// evict removes the victim of an expert chosen by weight and remembers it in
+// that expert's history. keep, if non-nil, and pinned bindings are never
+// evicted.
+func (lecar *LeCaR) evict(keep *Item) {
+	// LRU victim is the least recently used binding
+	skip := func(key string) bool {
+		return (keep != nil && key == keep.key) || lecar.pinned.pinned(key)
+	}
+	lruVictim := lecar.q.Back()
+	for lruVictim != nil && skip(lruVictim.Value.(string)) {
+		lruVictim = lruVictim.Prev()
+	}
+	// Both experts choose among the same bindings
+	if lruVictim == nil {
+		return
+	}
+
+	// LFU victim is the binding with the fewest accesses
+	lfuVictim := lecar.pq.popUnless(func(item *Item) bool { return skip(item.key) })
+	heap.Push(&lecar.pq, lfuVictim)
+
+	key := lfuVictim.key
+	history := lecar.lfuHistory
+	if lecar.rand.Float64() < lecar.weightLRU {
+		key = lruVictim.Value.(string)
+		history = lecar.lruHistory
+	}
+
+	value := *(lecar.lookup[key])
+	ghost := &lecarGhost{
+		key:      key,
+		evicted:  lecar.cacheAccesses,
+		accesses: lecar.items[key].accesses,
+	}
+	lecar.drop(key)
+
+	// Each history remembers about as many keys as the cache holds
+	limit := len(lecar.lookup) + 1
+	history.add(ghost, limit)
+	lecar.onEvict.evicted(key, value)
+}
+
+// Evict the victim of the LRU or LFU expert, chosen at random by weight
+func EvictLeCaR(lecar *LeCaR) {
+	lecar.evict(nil)
+}
+
+// Len returns the number of bindings in the LeCaR.
+func (lecar *LeCaR) Len() int {
+	return lecar.q.Len()
+}
+
+// Stats returns statistics about how many search hits and misses have
+// occurred, including the current weight of each expert.
+func (lecar *LeCaR) Stats() *Stats {
+	return lecar.stats
+}
+
+// OnEvict registers f to be called with each binding the LeCaR evicts.
+func (lecar *LeCaR) OnEvict(f EvictFunc) {
+	lecar.onEvict = append(lecar.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (lecar *LeCaR) Peek(key string) (value []byte, ok bool) {
+	valPointer := lecar.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this LeCaR, without
+// counting as a use or changing Stats.
+func (lecar *LeCaR) Contains(key string) bool {
+	return lecar.lookup[key] != nil
+}
+
+// Range calls f with each binding until f returns false, in the eviction
+// order of the expert with the most weight: least recently used first, or
+// least frequently used first. Eviction chooses between the experts at
+// random, so this is only the most likely order. f must not use the LeCaR.
+func (lecar *LeCaR) Range(f func(key string, value []byte) bool) {
+	if lecar.weightLFU > lecar.weightLRU {
+		for _, item := range lecar.pq.sorted() {
+			if !f(item.key, *lecar.lookup[item.key]) {
+				return
+			}
+		}
+		return
+	}
+	for el := lecar.q.Back(); el != nil; el = el.Prev() {
+		key := el.Value.(string)
+		if !f(key, *lecar.lookup[key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (lecar *LeCaR) Pin(key string) bool {
+	if lecar.lookup[key] == nil {
+		return false
+	}
+	lecar.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (lecar *LeCaR) Unpin(key string) bool {
+	return lecar.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (lecar *LeCaR) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(lecar, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (lecar *LeCaR) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(lecar, keys, values, func() { EvictLeCaR(lecar) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (lecar *LeCaR) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(lecar, keys)
+}
@@ -0,0 +1,153 @@
+/******************************************************************************
+ * lecar_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for lecar.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestLeCaRSetGet(t *testing.T) {
+	capacity := 64
+	lecar := NewLeCaR(capacity, 0.45, 0.9, 1)
+	checkCapacity(t, lecar, capacity)
+
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("key%d", i)
+		val := []byte(key)
+		ok := lecar.Set(key, val)
+		if !ok {
+			t.Errorf("Failed to add binding with key: %s", key)
+			t.FailNow()
+		}
+
+		res, _ := lecar.Get(key)
+		if !bytesEqual(res, val) {
+			t.Errorf("Wrong value %s for binding with key: %s", res, key)
+			t.FailNow()
+		}
+	}
+
+	// updating a key replaces its value
+	key := "key3"
+	val := []byte("a new value")
+	lecar.Set(key, val)
+	res, _ := lecar.Get(key)
+	if !bytesEqual(res, val) {
+		t.Errorf("Wrong value %s for binding with key: %s", res, key)
+		t.FailNow()
+	}
+	if lecar.RemainingStorage() != capacity-3*8-len(key)-len(val) {
+		t.Errorf("Wrong remaining storage %d after update", lecar.RemainingStorage())
+		t.FailNow()
+	}
+}
+
+func TestLeCaRRemove(t *testing.T) {
+	capacity := 64
+	lecar := NewLeCaR(capacity, 0.45, 0.9, 1)
+
+	key := "Hello"
+	val := []byte("World")
+	lecar.Set(key, val)
+
+	res, ok := lecar.Remove(key)
+	if !ok || !bytesEqual(res, val) {
+		t.Errorf("Failed to remove binding with key: %s", key)
+		t.FailNow()
+	}
+	if lecar.Len() != 0 || lecar.RemainingStorage() != capacity {
+		t.Errorf("LeCaR should be empty but has length %d", lecar.Len())
+		t.FailNow()
+	}
+}
+
+func TestLeCaRTooLarge(t *testing.T) {
+	capacity := 10
+	lecar := NewLeCaR(capacity, 0.45, 0.9, 1)
+
+	key := "123456"
+	ok := lecar.Set(key, []byte(key))
+	if ok {
+		t.Errorf("Should have failed to add binding with key: %s", key)
+		t.FailNow()
+	}
+}
+
+func TestLeCaRWeights(t *testing.T) {
+	lecar := NewLeCaR(100, 0.45, 0.9, 1)
+
+	weights := lecar.Stats().Weights
+	if weights["LRU"] != 0.5 || weights["LFU"] != 0.5 {
+		t.Errorf("Weights should start even, but are %v", weights)
+		t.FailNow()
+	}
+}
+
+// Hot keys mixed with a scan of keys that are never used again: LRU evicts the
+// hot keys, so LFU should win.
+func TestLeCaRLearnsLFU(t *testing.T) {
+	capacity := 100
+	lecar := NewLeCaR(capacity, 0.45, 0.9, 1)
+
+	for i := 0; i < 2000; i++ {
+		for _, key := range []string{fmt.Sprintf("%05d", i%8), fmt.Sprintf("%05d", 1000+i)} {
+			if _, found := lecar.Get(key); !found {
+				lecar.Set(key, []byte(key))
+			}
+		}
+	}
+
+	checkWeights(t, lecar.Stats())
+	if lecar.Stats().Weights["LFU"] <= 0.5 {
+		t.Errorf("LFU should be winning, but weights are %v", lecar.Stats().Weights)
+		t.FailNow()
+	}
+}
+
+// Keys that were hot in the past go cold: LFU keeps them and evicts the new
+// working set, so LRU should win.
+func TestLeCaRLearnsLRU(t *testing.T) {
+	capacity := 100
+	lecar := NewLeCaR(capacity, 0.45, 0.9, 1)
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("%05d", i)
+		lecar.Set(key, []byte(key))
+		for j := 0; j < 50; j++ {
+			lecar.Get(key)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("%05d", 100+i%8)
+		if _, found := lecar.Get(key); !found {
+			lecar.Set(key, []byte(key))
+		}
+	}
+
+	checkWeights(t, lecar.Stats())
+	if lecar.Stats().Weights["LRU"] <= 0.5 {
+		t.Errorf("LRU should be winning, but weights are %v", lecar.Stats().Weights)
+		t.FailNow()
+	}
+}
+
+// Fails test t if the expert weights don't add up to one
+func checkWeights(t *testing.T, stats *Stats) {
+	total := stats.Weights["LRU"] + stats.Weights["LFU"]
+	if math.Abs(total-1) > 1e-9 {
+		t.Errorf("Weights should add up to 1, but are %v", stats.Weights)
+		t.FailNow()
+	}
+}
@@ -13,6 +13,8 @@ type LFU struct {
 	maxSize int
 	currSize int
 	stats *Stats
+	onEvict evictHandlers
+	pinned pins
 }
 
 // NewLFU returns a pointer to a new LFU with a capacity to store limit bytes
@@ -30,6 +32,7 @@ func NewLfu(limit int) *LFU {
 	cache.stats = new(Stats)
 	cache.stats.Hits = 0
 	cache.stats.Misses = 0
+	cache.pinned = pins{}
 	return cache
 }
 
@@ -43,6 +46,18 @@ func (lfu *LFU) RemainingStorage() int {
 	return lfu.maxSize - lfu.currSize
 }
 
+// Resize changes the capacity of this LFU to limit bytes, evicting bindings
+// in the usual order until they fit.
+func (lfu *LFU) Resize(limit int) {
+	if pinned := lfu.pinned.size(lfu.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	lfu.maxSize = limit
+	for lfu.currSize > lfu.maxSize {
+		EvictLFU(lfu)
+	}
+}
+
 // Get returns the value associated with the given key, if it exists.
 // This operation counts as a "use" for that key-value pair
 // ok is true if a value was found and false otherwise.
@@ -50,7 +65,7 @@ func (lfu *LFU) Get(key string) (value []byte, ok bool) {
 	valPointer := lfu.lookup[key]
 
 	if valPointer == nil {
-		lfu.stats.Misses++
+		lfu.stats.miss()
 		return nil, false
 	}
 
@@ -69,7 +84,7 @@ func (lfu *LFU) Get(key string) (value []byte, ok bool) {
 	// }
 	// fmt.Println()
 
-	lfu.stats.Hits++
+	lfu.stats.hit()
 	return *valPointer, true
 }
 
@@ -82,6 +97,8 @@ func (lfu *LFU) Remove(key string) (value []byte, ok bool) {
 		return nil, false
 	}
 
+	delete(lfu.pinned, key)
+
 	delete(lfu.lookup, key)
 
 	// remove matching element from priority queue
@@ -103,6 +120,11 @@ func (lfu *LFU) Set(key string, value []byte) bool {
 			return false
 		}
 
+		// Pinned bindings can't be evicted to make room
+		if newElSize > lfu.maxSize-lfu.pinned.size(lfu.lookup, key) {
+			return false
+		}
+
 		// // print key value pair
 		// fmt.Printf("%s: %s\n", key, value)
 		// fmt.Println("------------------------------------------------------")
@@ -116,6 +138,12 @@ func (lfu *LFU) Set(key string, value []byte) bool {
 			addedSize = len(value) - len(*existingVal)
 		}
 	    
+		// Take an updated binding out of the queue while making room, so that it
+		// can't be evicted to make room for itself
+		if existsInQ {
+			lfu.pq.Remove(lfu.items[key])
+		}
+
 		// Evict until there's enough room
 		for lfu.currSize+addedSize > lfu.maxSize {
 			EvictLFU(lfu)
@@ -130,12 +158,10 @@ func (lfu *LFU) Set(key string, value []byte) bool {
 	
 		// Add new key:value pair
 		if existsInQ {
-			existingItemPointer := lfu.items[key]
-			existingItem := *existingItemPointer
-			lfu.pq.Update(existingItemPointer, existingItem.priority + 1)
-			existingItem.priority++
-			lfu.lookup[key] = existingVal
-			lfu.items[key] = &existingItem
+			item := lfu.items[key]
+			heap.Push(&lfu.pq, item)
+			lfu.pq.Update(item, item.priority+1)
+			lfu.lookup[key] = &value
 			lfu.currSize += addedSize
 		// Only add to the queue if it doesn't exist yet
 		} else {
@@ -156,13 +182,17 @@ func (lfu *LFU) Set(key string, value []byte) bool {
 
 // Evict the last element added to list
 func EvictLFU(lfu *LFU) {
-	item := heap.Pop(&lfu.pq).(*Item)
+	item := lfu.pq.popUnless(func(item *Item) bool { return lfu.pinned.pinned(item.key) })
+	if item == nil {
+		return
+	}
 	// fmt.Printf("Evicting %s: %s. Accesses: %d\n", item.key, string(*lfu.lookup[item.key]), item.priority)
 	key := item.key
 	value := *(lfu.lookup[key])
 	delete(lfu.lookup, key)
 	delete(lfu.items, key)
 	lfu.currSize -= len(key) + len(value)
+	lfu.onEvict.evicted(key, value)
 }
 
 // Len returns the number of bindings in the LFU.
@@ -174,3 +204,68 @@ func (lfu *LFU) Len() int {
 func (lfu *LFU) Stats() *Stats {
 	return lfu.stats
 }
+
+// OnEvict registers f to be called with each binding the LFU evicts.
+func (lfu *LFU) OnEvict(f EvictFunc) {
+	lfu.onEvict = append(lfu.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (lfu *LFU) Peek(key string) (value []byte, ok bool) {
+	valPointer := lfu.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this LFU, without
+// counting as a use or changing Stats.
+func (lfu *LFU) Contains(key string) bool {
+	return lfu.lookup[key] != nil
+}
+
+// Range calls f with each binding in eviction order, lowest priority first,
+// until f returns false. f must not use the LFU.
+func (lfu *LFU) Range(f func(key string, value []byte) bool) {
+	for _, item := range lfu.pq.sorted() {
+		if !f(item.key, *lfu.lookup[item.key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (lfu *LFU) Pin(key string) bool {
+	if lfu.lookup[key] == nil {
+		return false
+	}
+	lfu.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (lfu *LFU) Unpin(key string) bool {
+	return lfu.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (lfu *LFU) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(lfu, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (lfu *LFU) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(lfu, keys, values, func() { EvictLFU(lfu) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (lfu *LFU) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(lfu, keys)
+}
@@ -13,10 +13,25 @@ type LFUDA struct {
 	maxSize int
 	currSize int
 	stats *Stats
+	onEvict evictHandlers
+	pinned pins
 
 	cacheAccesses int
+
+	// Priorities grow with cacheAccesses, so every lfudaRenormalizeEvery
+	// accesses they are renormalized to stay within lfudaPriorityFloor and
+	// about lfudaRenormalizeEvery.
+	newPriority      float64 // priority of a newly added binding
+	sinceRenormalize int
 }
 
+// lfudaRenormalizeEvery is the number of accesses between renormalizations
+// of an LFUDA's priorities.
+const lfudaRenormalizeEvery = 1 << 20
+
+// lfudaPriorityFloor is the lowest priority left by a renormalization.
+const lfudaPriorityFloor = -2 * lfudaRenormalizeEvery
+
 // NewLFUDA returns a pointer to a new LFUDA with a capacity to store limit bytes
 func NewLFUDA(limit int) *LFUDA {
 	cache := new(LFUDA)
@@ -35,6 +50,9 @@ func NewLFUDA(limit int) *LFUDA {
 
 	// Constant multiplier for the priority of a key
 	cache.cacheAccesses = 0
+	cache.newPriority = 1.0
+	cache.sinceRenormalize = 0
+	cache.pinned = pins{}
 	return cache
 }
 
@@ -48,15 +66,27 @@ func (lfu *LFUDA) RemainingStorage() int {
 	return lfu.maxSize - lfu.currSize
 }
 
+// Resize changes the capacity of this LFUDA to limit bytes, evicting bindings
+// in the usual order until they fit.
+func (lfu *LFUDA) Resize(limit int) {
+	if pinned := lfu.pinned.size(lfu.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	lfu.maxSize = limit
+	for lfu.currSize > lfu.maxSize {
+		EvictLFUDA(lfu)
+	}
+}
+
 // Get returns the value associated with the given key, if it exists.
 // This operation counts as a "use" for that key-value pair
 // ok is true if a value was found and false otherwise.
 func (lfu *LFUDA) Get(key string) (value []byte, ok bool) {
-	lfu.cacheAccesses++
+	lfu.access()
 	valPointer := lfu.lookup[key]
 
 	if valPointer == nil {
-		lfu.stats.Misses++
+		lfu.stats.miss()
 		return nil, false
 	}
 
@@ -76,7 +106,7 @@ func (lfu *LFUDA) Get(key string) (value []byte, ok bool) {
 	// }
 	// fmt.Println()
 
-	lfu.stats.Hits++
+	lfu.stats.hit()
 	return *valPointer, true
 }
 
@@ -85,6 +115,38 @@ func (lfu *LFUDA) getLFUDAPriority(accesses int) float64 {
 	return float64(accesses) + float64(lfu.cacheAccesses)
 }
 
+// access counts a cache access, renormalizing priorities when it's time to.
+func (lfu *LFUDA) access() {
+	lfu.cacheAccesses++
+	lfu.sinceRenormalize++
+	if lfu.sinceRenormalize >= lfudaRenormalizeEvery {
+		lfu.renormalize()
+	}
+}
+
+// renormalize resets the access count to zero and shifts every priority down
+// by as much, which doesn't change their order. Bindings that haven't been
+// used since they were added have the lowest priorities, which would go
+// further negative on every renormalization, so priorities and newPriority
+// are raised to lfudaPriorityFloor if they are below it. Only bindings last
+// used over lfudaPriorityFloor accesses ago can end up tied with each other
+// or with new ones, and they are all still below every other binding.
+func (lfu *LFUDA) renormalize() {
+	lfu.sinceRenormalize = 0
+	baseline := float64(lfu.cacheAccesses)
+	for _, item := range lfu.pq {
+		item.priority -= baseline
+		if item.priority < lfudaPriorityFloor {
+			item.priority = lfudaPriorityFloor
+		}
+	}
+	lfu.cacheAccesses = 0
+	lfu.newPriority -= baseline
+	if lfu.newPriority < lfudaPriorityFloor {
+		lfu.newPriority = lfudaPriorityFloor
+	}
+}
+
 // Remove removes and returns the value associated with the given key, if it exists.
 // ok is true if a value was found and false otherwise
 func (lfu *LFUDA) Remove(key string) (value []byte, ok bool) {
@@ -94,6 +156,8 @@ func (lfu *LFUDA) Remove(key string) (value []byte, ok bool) {
 		return nil, false
 	}
 
+	delete(lfu.pinned, key)
+
 	delete(lfu.lookup, key)
 
 	// remove matching element from priority queue
@@ -109,7 +173,7 @@ func (lfu *LFUDA) Remove(key string) (value []byte, ok bool) {
 // Set associates the given value with the given key, possibly evicting values
 // to make room. Returns true if the binding was added successfully, else false.
 func (lfu *LFUDA) Set(key string, value []byte) bool {
-	lfu.cacheAccesses++
+	lfu.access()
 
 	// Check to see if too large for cache
 	newElSize := len(key) + len(value)
@@ -117,6 +181,11 @@ func (lfu *LFUDA) Set(key string, value []byte) bool {
 		return false
 	}
 
+	// Pinned bindings can't be evicted to make room
+	if newElSize > lfu.maxSize-lfu.pinned.size(lfu.lookup, key) {
+		return false
+	}
+
 	// // print key value pair
 	// fmt.Printf("%s: %s\n", key, value)
 	// fmt.Println("------------------------------------------------------")
@@ -130,6 +199,12 @@ func (lfu *LFUDA) Set(key string, value []byte) bool {
 		addedSize = len(value) - len(*existingVal)
 	}
 		
+	// Take an updated binding out of the queue while making room, so that it
+	// can't be evicted to make room for itself
+	if existsInQ {
+		lfu.pq.Remove(lfu.items[key])
+	}
+
 	// Evict until there's enough room
 	for lfu.currSize+addedSize > lfu.maxSize {
 		EvictLFUDA(lfu)
@@ -144,18 +219,16 @@ func (lfu *LFUDA) Set(key string, value []byte) bool {
 
 	// Add new key:value pair
 	if existsInQ {
-		existingItemPointer := lfu.items[key]
-		existingItem := *existingItemPointer
-		lfu.pq.Update(existingItemPointer, existingItem.priority + 1)
-		existingItem.priority++
-		lfu.lookup[key] = existingVal
-		lfu.items[key] = &existingItem
+		item := lfu.items[key]
+		heap.Push(&lfu.pq, item)
+		lfu.pq.Update(item, item.priority+1)
+		lfu.lookup[key] = &value
 		lfu.currSize += addedSize
 	// Only add to the queue if it doesn't exist yet
 	} else {
 		item := &Item{
 			key: key, 
-			priority: 1.0,
+			priority: lfu.newPriority,
 			accesses: 1,
 		}
 
@@ -170,13 +243,17 @@ func (lfu *LFUDA) Set(key string, value []byte) bool {
 
 // Evict the last element added to list
 func EvictLFUDA(lfu *LFUDA) {
-	item := heap.Pop(&lfu.pq).(*Item)
+	item := lfu.pq.popUnless(func(item *Item) bool { return lfu.pinned.pinned(item.key) })
+	if item == nil {
+		return
+	}
 	// fmt.Printf("Evicting %s: %s. Accesses: %d\n", item.key, string(*lfu.lookup[item.key]), item.priority)
 	key := item.key
 	value := *(lfu.lookup[key])
 	delete(lfu.lookup, key)
 	delete(lfu.items, key)
 	lfu.currSize -= len(key) + len(value)
+	lfu.onEvict.evicted(key, value)
 }
 
 // Len returns the number of bindings in the LFUDA.
@@ -188,3 +265,68 @@ func (lfu *LFUDA) Len() int {
 func (lfu *LFUDA) Stats() *Stats {
 	return lfu.stats
 }
+
+// OnEvict registers f to be called with each binding the LFUDA evicts.
+func (lfu *LFUDA) OnEvict(f EvictFunc) {
+	lfu.onEvict = append(lfu.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (lfu *LFUDA) Peek(key string) (value []byte, ok bool) {
+	valPointer := lfu.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this LFUDA, without
+// counting as a use or changing Stats.
+func (lfu *LFUDA) Contains(key string) bool {
+	return lfu.lookup[key] != nil
+}
+
+// Range calls f with each binding in eviction order, lowest priority first,
+// until f returns false. f must not use the LFUDA.
+func (lfu *LFUDA) Range(f func(key string, value []byte) bool) {
+	for _, item := range lfu.pq.sorted() {
+		if !f(item.key, *lfu.lookup[item.key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (lfu *LFUDA) Pin(key string) bool {
+	if lfu.lookup[key] == nil {
+		return false
+	}
+	lfu.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (lfu *LFUDA) Unpin(key string) bool {
+	return lfu.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (lfu *LFUDA) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(lfu, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (lfu *LFUDA) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(lfu, keys, values, func() { EvictLFUDA(lfu) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (lfu *LFUDA) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(lfu, keys)
+}
@@ -10,6 +10,8 @@ package cache
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -511,3 +513,97 @@ func TestLFUDAAllHits(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+// Renormalizing never lowers priorities past lfudaPriorityFloor, even for
+// bindings that are never used again.
+func TestLFUDARenormalizeBounded(t *testing.T) {
+	lfu := NewLFUDA(100)
+	lfu.Set("unused", []byte("value"))
+	lfu.Set("used", []byte("value"))
+	for i := 0; i < 1000; i++ {
+		lfu.Get("used")
+		lfu.cacheAccesses = lfudaRenormalizeEvery
+		lfu.renormalize()
+		if lfu.newPriority < lfudaPriorityFloor || lfu.newPriority > 1 {
+			t.Errorf("After %d renormalizations newPriority is %f", i+1, lfu.newPriority)
+			t.FailNow()
+		}
+		for key, item := range lfu.items {
+			if item.priority < lfudaPriorityFloor || item.priority > lfudaRenormalizeEvery {
+				t.Errorf("After %d renormalizations %s has priority %f", i+1, key, item.priority)
+				t.FailNow()
+			}
+		}
+	}
+
+	lfu.Set("new", []byte("value"))
+	if lfu.pq[0].key == "used" {
+		t.Errorf("Used binding has the lowest priority after renormalizing")
+		t.FailNow()
+	}
+}
+
+// Over tens of millions of accesses, renormalization keeps priorities exact:
+// every eviction is of a binding with the lowest priority computed without
+// renormalizing.
+func TestLFUDALongRunOrder(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping long run in short mode")
+	}
+
+	lfu := NewLFUDA(128)
+	clock := int64(0)
+	priorities := map[string]int64{}
+	lfu.OnEvict(func(key string, value []byte) {
+		for other := range lfu.items {
+			if priorities[other] < priorities[key] {
+				t.Errorf("After %d accesses evicted %s with priority %d, but %s has priority %d",
+					clock, key, priorities[key], other, priorities[other])
+				t.FailNow()
+			}
+		}
+		delete(priorities, key)
+	})
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000000; i++ {
+		key := fmt.Sprintf("%04d", rng.Intn(64))
+		clock++
+		if _, found := lfu.Get(key); found {
+			priorities[key] = int64(lfu.items[key].accesses) + clock
+			continue
+		}
+		clock++
+		lfu.Set(key, []byte(key))
+		priorities[key] = 1
+	}
+
+	// bindings never used since they were added have the priority of a new
+	// one, and every other priority is off from the exact one by the same
+	// baseline
+	offset := math.NaN()
+	for key, item := range lfu.items {
+		if priorities[key] == 1 {
+			if item.priority != lfu.newPriority {
+				t.Errorf("Unused %s has priority %f, but new bindings get %f", key, item.priority, lfu.newPriority)
+				t.FailNow()
+			}
+			continue
+		}
+		diff := float64(priorities[key]) - item.priority
+		if !math.IsNaN(offset) && diff != offset {
+			t.Errorf("Priority of %s is off by %f, expected %f", key, diff, offset)
+			t.FailNow()
+		}
+		offset = diff
+	}
+	if lfu.cacheAccesses >= lfudaRenormalizeEvery {
+		t.Errorf("After %d accesses cacheAccesses is %d, it should have been renormalized",
+			clock, lfu.cacheAccesses)
+		t.FailNow()
+	}
+	if lfu.newPriority < lfudaPriorityFloor {
+		t.Errorf("After %d accesses newPriority is %f, below %d", clock, lfu.newPriority, lfudaPriorityFloor)
+		t.FailNow()
+	}
+}
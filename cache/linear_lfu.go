@@ -13,6 +13,8 @@ type LinearLFU struct {
 	maxSize  int
 	currSize int
 	stats    *Stats
+	onEvict  evictHandlers
+	pinned   pins
 
 	alpha         float64
 	cacheAccesses int
@@ -37,6 +39,7 @@ func NewLinearLfu(limit int, alpha float64) *LinearLFU {
 	// Constant multiplier for the priority of a key
 	cache.alpha = alpha
 	cache.cacheAccesses = 0
+	cache.pinned = pins{}
 	return cache
 }
 
@@ -50,6 +53,18 @@ func (lfu *LinearLFU) RemainingStorage() int {
 	return lfu.maxSize - lfu.currSize
 }
 
+// Resize changes the capacity of this LinearLFU to limit bytes, evicting bindings
+// in the usual order until they fit.
+func (lfu *LinearLFU) Resize(limit int) {
+	if pinned := lfu.pinned.size(lfu.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	lfu.maxSize = limit
+	for lfu.currSize > lfu.maxSize {
+		EvictLinearLFU(lfu)
+	}
+}
+
 // Get returns the value associated with the given key, if it exists.
 // This operation counts as a "use" for that key-value pair
 // ok is true if a value was found and false otherwise.
@@ -58,7 +73,7 @@ func (lfu *LinearLFU) Get(key string) (value []byte, ok bool) {
 	valPointer := lfu.lookup[key]
 
 	if valPointer == nil {
-		lfu.stats.Misses++
+		lfu.stats.miss()
 		return nil, false
 	}
 
@@ -78,7 +93,7 @@ func (lfu *LinearLFU) Get(key string) (value []byte, ok bool) {
 	// }
 	// fmt.Println()
 
-	lfu.stats.Hits++
+	lfu.stats.hit()
 	return *valPointer, true
 }
 
@@ -96,6 +111,8 @@ func (lfu *LinearLFU) Remove(key string) (value []byte, ok bool) {
 		return nil, false
 	}
 
+	delete(lfu.pinned, key)
+
 	delete(lfu.lookup, key)
 
 	// remove matching element from priority queue
@@ -119,6 +136,11 @@ func (lfu *LinearLFU) Set(key string, value []byte) bool {
 		return false
 	}
 
+	// Pinned bindings can't be evicted to make room
+	if newElSize > lfu.maxSize-lfu.pinned.size(lfu.lookup, key) {
+		return false
+	}
+
 	// // print key value pair
 	// fmt.Printf("%s: %s\n", key, value)
 	// fmt.Println("------------------------------------------------------")
@@ -132,6 +154,12 @@ func (lfu *LinearLFU) Set(key string, value []byte) bool {
 		addedSize = len(value) - len(*existingVal)
 	}
 
+	// Take an updated binding out of the queue while making room, so that it
+	// can't be evicted to make room for itself
+	if existsInQ {
+		lfu.pq.Remove(lfu.items[key])
+	}
+
 	// Evict until there's enough room
 	for lfu.currSize+addedSize > lfu.maxSize {
 		EvictLinearLFU(lfu)
@@ -145,12 +173,10 @@ func (lfu *LinearLFU) Set(key string, value []byte) bool {
 
 	// Add new key:value pair
 	if existsInQ {
-		existingItemPointer := lfu.items[key]
-		existingItem := *existingItemPointer
-		lfu.pq.Update(existingItemPointer, existingItem.priority+1)
-		existingItem.priority++
-		lfu.lookup[key] = existingVal
-		lfu.items[key] = &existingItem
+		item := lfu.items[key]
+		heap.Push(&lfu.pq, item)
+		lfu.pq.Update(item, item.priority+1)
+		lfu.lookup[key] = &value
 		lfu.currSize += addedSize
 		// Only add to the queue if it doesn't exist yet
 	} else {
@@ -171,13 +197,17 @@ func (lfu *LinearLFU) Set(key string, value []byte) bool {
 
 // Evict the last element added to list
 func EvictLinearLFU(lfu *LinearLFU) {
-	item := heap.Pop(&lfu.pq).(*Item)
+	item := lfu.pq.popUnless(func(item *Item) bool { return lfu.pinned.pinned(item.key) })
+	if item == nil {
+		return
+	}
 	// fmt.Printf("Evicting %s: %s. Accesses: %d\n", item.key, string(*lfu.lookup[item.key]), item.priority)
 	key := item.key
 	value := *(lfu.lookup[key])
 	delete(lfu.lookup, key)
 	delete(lfu.items, key)
 	lfu.currSize -= len(key) + len(value)
+	lfu.onEvict.evicted(key, value)
 }
 
 // Len returns the number of bindings in the LinearLFU.
@@ -189,3 +219,68 @@ func (lfu *LinearLFU) Len() int {
 func (lfu *LinearLFU) Stats() *Stats {
 	return lfu.stats
 }
+
+// OnEvict registers f to be called with each binding the LinearLFU evicts.
+func (lfu *LinearLFU) OnEvict(f EvictFunc) {
+	lfu.onEvict = append(lfu.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (lfu *LinearLFU) Peek(key string) (value []byte, ok bool) {
+	valPointer := lfu.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this LinearLFU, without
+// counting as a use or changing Stats.
+func (lfu *LinearLFU) Contains(key string) bool {
+	return lfu.lookup[key] != nil
+}
+
+// Range calls f with each binding in eviction order, lowest priority first,
+// until f returns false. f must not use the LinearLFU.
+func (lfu *LinearLFU) Range(f func(key string, value []byte) bool) {
+	for _, item := range lfu.pq.sorted() {
+		if !f(item.key, *lfu.lookup[item.key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (lfu *LinearLFU) Pin(key string) bool {
+	if lfu.lookup[key] == nil {
+		return false
+	}
+	lfu.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (lfu *LinearLFU) Unpin(key string) bool {
+	return lfu.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (lfu *LinearLFU) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(lfu, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (lfu *LinearLFU) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(lfu, keys, values, func() { EvictLinearLFU(lfu) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (lfu *LinearLFU) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(lfu, keys)
+}
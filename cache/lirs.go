@@ -0,0 +1,426 @@
+package cache
+
+import (
+	"container/list"
+)
+
+// lirsGhostFactor bounds the number of non-resident HIR entries kept in the
+// stack S to this multiple of the number of resident entries.
+const lirsGhostFactor = 2
+
+// lirsEntry is the metadata LIRS keeps for every key it knows about,
+// resident or not.
+type lirsEntry struct {
+	key      string
+	size     int
+	lir      bool
+	resident bool
+
+	sElem *list.Element // position in the LIRS stack S, nil if not in S
+	qElem *list.Element // position in the resident HIR queue Q, nil if not in Q
+	gElem *list.Element // position in the non-resident list, nil if resident
+}
+
+// An LIRS is a fixed-size in-memory cache with low inter-reference recency set
+// eviction. Entries are split into LIR entries, which are never evicted
+// directly, and HIR entries, which are evicted from the queue Q. The stack S
+// orders entries by recency and also remembers non-resident HIR entries so a
+// key that comes back quickly can be promoted to LIR.
+type LIRS struct {
+	lookup   map[string]*[]byte
+	entries  map[string]*lirsEntry
+	s        *list.List // front is the top of the stack
+	q        *list.List // front is the next HIR entry to evict
+	ghosts   *list.List // non-resident entries, front is the oldest
+	maxSize  int
+	currSize int
+	stats    *Stats
+	onEvict  evictHandlers
+	pinned   pins
+
+	lirSize  int
+	lirLimit int
+	hirRatio float64
+}
+
+// NewLIRS returns a pointer to a new LIRS with a capacity to store limit bytes.
+// hirRatio is the fraction of limit reserved for resident HIR entries; values
+// around 0.01 to 0.1 are typical.
+func NewLIRS(limit int, hirRatio float64) *LIRS {
+	cache := new(LIRS)
+	cache.lookup = map[string]*[]byte{}
+	cache.entries = map[string]*lirsEntry{}
+	cache.s = list.New()
+	cache.q = list.New()
+	cache.ghosts = list.New()
+	cache.maxSize = limit
+	cache.currSize = 0
+	cache.stats = new(Stats)
+
+	cache.lirSize = 0
+	cache.lirLimit = int(float64(limit) * (1 - hirRatio))
+	cache.hirRatio = hirRatio
+	cache.pinned = pins{}
+	return cache
+}
+
+// MaxStorage returns the maximum number of bytes this LIRS can store
+func (lirs *LIRS) MaxStorage() int {
+	return lirs.maxSize
+}
+
+// RemainingStorage returns the number of unused bytes available in this LIRS
+func (lirs *LIRS) RemainingStorage() int {
+	return lirs.maxSize - lirs.currSize
+}
+
+// Resize changes the capacity of this LIRS to limit bytes, keeping the same
+// fraction of it for resident HIR entries. LIR entries are demoted from the
+// bottom of S until the LIR set fits, then bindings are evicted in the usual
+// order until they all fit.
+func (lirs *LIRS) Resize(limit int) {
+	if pinned := lirs.pinned.size(lirs.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	lirs.maxSize = limit
+	lirs.lirLimit = int(float64(limit) * (1 - lirs.hirRatio))
+	for lirs.lirSize > lirs.lirLimit {
+		lirs.demote()
+	}
+	lirs.makeRoom(0, nil)
+	lirs.trimGhosts()
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (lirs *LIRS) Get(key string) (value []byte, ok bool) {
+	valPointer := lirs.lookup[key]
+
+	if valPointer == nil {
+		lirs.stats.miss()
+		return nil, false
+	}
+
+	lirs.access(lirs.entries[key])
+
+	lirs.stats.hit()
+	return *valPointer, true
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (lirs *LIRS) Remove(key string) (value []byte, ok bool) {
+	valPointer := lirs.lookup[key]
+
+	if valPointer == nil {
+		return nil, false
+	}
+
+	delete(lirs.pinned, key)
+
+	lirs.forget(lirs.entries[key])
+	lirs.prune()
+	return *valPointer, true
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+func (lirs *LIRS) Set(key string, value []byte) bool {
+	// Check to see if too large for cache
+	newElSize := len(key) + len(value)
+	if newElSize > lirs.maxSize {
+		return false
+	}
+
+	// Pinned bindings can't be evicted to make room
+	if newElSize > lirs.maxSize-lirs.pinned.size(lirs.lookup, key) {
+		return false
+	}
+
+	entry := lirs.entries[key]
+
+	// Updating a resident key counts as a use of it
+	if entry != nil && entry.resident {
+		lirs.currSize += newElSize - entry.size
+		if entry.lir {
+			lirs.lirSize += newElSize - entry.size
+		}
+		entry.size = newElSize
+		lirs.lookup[key] = &value
+		lirs.access(entry)
+		if entry.lir {
+			lirs.shrinkLIR(entry)
+		}
+		lirs.makeRoom(0, entry)
+		return true
+	}
+
+	lirs.makeRoom(newElSize, nil)
+
+	// The key may have been pruned from S while making room
+	entry = lirs.entries[key]
+	if entry == nil {
+		entry = &lirsEntry{key: key}
+		lirs.entries[key] = entry
+	} else {
+		lirs.ghosts.Remove(entry.gElem)
+		entry.gElem = nil
+	}
+	entry.size = newElSize
+	entry.resident = true
+	lirs.lookup[key] = &value
+	lirs.currSize += newElSize
+
+	if entry.sElem != nil || lirs.lirSize+newElSize <= lirs.lirLimit {
+		// A non-resident HIR entry still in S has a small inter-reference
+		// recency, as does anything that fits while the LIR set warms up
+		lirs.promote(entry)
+	} else {
+		entry.sElem = lirs.s.PushFront(entry)
+		entry.qElem = lirs.q.PushBack(entry)
+	}
+	lirs.trimGhosts()
+	return true
+}
+
+// access moves a resident entry to the top of S, promoting it to LIR if it is
+// an HIR entry whose previous reference is still recorded in S.
+func (lirs *LIRS) access(entry *lirsEntry) {
+	if entry.lir {
+		lirs.s.MoveToFront(entry.sElem)
+		lirs.prune()
+		return
+	}
+
+	if entry.sElem != nil {
+		lirs.promote(entry)
+		return
+	}
+
+	// HIR entry without a recent reference stays HIR
+	entry.sElem = lirs.s.PushFront(entry)
+	lirs.q.MoveToBack(entry.qElem)
+}
+
+// promote turns a resident entry into an LIR entry at the top of S.
+func (lirs *LIRS) promote(entry *lirsEntry) {
+	if entry.qElem != nil {
+		lirs.q.Remove(entry.qElem)
+		entry.qElem = nil
+	}
+	if entry.sElem != nil {
+		lirs.s.MoveToFront(entry.sElem)
+	} else {
+		entry.sElem = lirs.s.PushFront(entry)
+	}
+	entry.lir = true
+	lirs.lirSize += entry.size
+	lirs.shrinkLIR(entry)
+}
+
+// shrinkLIR demotes LIR entries from the bottom of S until the LIR set fits in
+// lirLimit or only top is left.
+func (lirs *LIRS) shrinkLIR(top *lirsEntry) {
+	for lirs.lirSize > lirs.lirLimit && lirs.s.Back().Value.(*lirsEntry) != top {
+		lirs.demote()
+	}
+	lirs.prune()
+}
+
+// demote turns the LIR entry at the bottom of S into a resident HIR entry at
+// the end of Q.
+func (lirs *LIRS) demote() {
+	// HIR entries can be left at the bottom of S while there are no LIR
+	// entries below them, such as when the first ones were too large to fit
+	// in the LIR set
+	lirs.prune()
+	bottom := lirs.s.Back().Value.(*lirsEntry)
+	bottom.lir = false
+	lirs.lirSize -= bottom.size
+	lirs.s.Remove(bottom.sElem)
+	bottom.sElem = nil
+	bottom.qElem = lirs.q.PushBack(bottom)
+	lirs.prune()
+}
+
+// prune removes HIR entries from the bottom of S so that S always ends in an
+// LIR entry. Non-resident entries removed this way are forgotten entirely.
+func (lirs *LIRS) prune() {
+	for lirs.s.Len() > 0 {
+		bottom := lirs.s.Back().Value.(*lirsEntry)
+		if bottom.lir {
+			return
+		}
+		lirs.s.Remove(bottom.sElem)
+		bottom.sElem = nil
+		if !bottom.resident {
+			lirs.ghosts.Remove(bottom.gElem)
+			delete(lirs.entries, bottom.key)
+		}
+	}
+}
+
+// makeRoom evicts resident HIR entries until size more bytes fit. keep, if
+// non-nil, is never evicted.
+func (lirs *LIRS) makeRoom(size int, keep *lirsEntry) {
+	for lirs.currSize+size > lirs.maxSize {
+		lirs.evict(keep)
+	}
+}
+
+// victim returns the entry nearest the front of Q other than keep that isn't
+// pinned, or nil if there is none.
+func (lirs *LIRS) victim(keep *lirsEntry) *lirsEntry {
+	for e := lirs.q.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*lirsEntry)
+		if entry != keep && !lirs.pinned.pinned(entry.key) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// evict evicts the victim in Q, keeping it as a non-resident entry if it is
+// still in S. Bottom LIR entries are demoted until Q has a victim.
+func (lirs *LIRS) evict(keep *lirsEntry) {
+	entry := lirs.victim(keep)
+	for entry == nil {
+		// Every resident entry is in Q, so there is nothing to demote
+		if lirs.q.Len() == len(lirs.lookup) {
+			return
+		}
+		lirs.demote()
+		entry = lirs.victim(keep)
+	}
+	value := *(lirs.lookup[entry.key])
+
+	if entry.sElem == nil {
+		lirs.forget(entry)
+	} else {
+		lirs.q.Remove(entry.qElem)
+		entry.qElem = nil
+		entry.resident = false
+		entry.gElem = lirs.ghosts.PushBack(entry)
+		delete(lirs.lookup, entry.key)
+		lirs.currSize -= entry.size
+	}
+	lirs.onEvict.evicted(entry.key, value)
+}
+
+// trimGhosts forgets the oldest non-resident entries once there are more than
+// lirsGhostFactor times the number of resident entries.
+func (lirs *LIRS) trimGhosts() {
+	for lirs.ghosts.Len() > lirsGhostFactor*len(lirs.lookup) {
+		oldest := lirs.ghosts.Front().Value.(*lirsEntry)
+		lirs.ghosts.Remove(oldest.gElem)
+		lirs.s.Remove(oldest.sElem)
+		delete(lirs.entries, oldest.key)
+	}
+}
+
+// forget removes every trace of a resident entry from the LIRS.
+func (lirs *LIRS) forget(entry *lirsEntry) {
+	if entry.lir {
+		lirs.lirSize -= entry.size
+	}
+	if entry.sElem != nil {
+		lirs.s.Remove(entry.sElem)
+	}
+	if entry.qElem != nil {
+		lirs.q.Remove(entry.qElem)
+	}
+	delete(lirs.lookup, entry.key)
+	delete(lirs.entries, entry.key)
+	lirs.currSize -= entry.size
+}
+
+// Evict the resident HIR entry nearest the front of Q that isn't pinned,
+// keeping it as a non-resident entry if it is still in S. If Q has none the
+// bottom LIR entries are demoted first.
+func EvictLIRS(lirs *LIRS) {
+	lirs.evict(nil)
+}
+
+// Len returns the number of bindings in the LIRS.
+func (lirs *LIRS) Len() int {
+	return len(lirs.lookup)
+}
+
+// Stats returns statistics about how many search hits and misses have occurred.
+func (lirs *LIRS) Stats() *Stats {
+	return lirs.stats
+}
+
+// OnEvict registers f to be called with each binding the LIRS evicts.
+func (lirs *LIRS) OnEvict(f EvictFunc) {
+	lirs.onEvict = append(lirs.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (lirs *LIRS) Peek(key string) (value []byte, ok bool) {
+	valPointer := lirs.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this LIRS, without
+// counting as a use or changing Stats.
+func (lirs *LIRS) Contains(key string) bool {
+	return lirs.lookup[key] != nil
+}
+
+// Range calls f with each binding in eviction order until f returns false:
+// the resident HIR entries in Q, then the LIR entries from the bottom of S,
+// which are demoted in that order once Q is empty. f must not use the LIRS.
+func (lirs *LIRS) Range(f func(key string, value []byte) bool) {
+	for el := lirs.q.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*lirsEntry)
+		if !f(entry.key, *lirs.lookup[entry.key]) {
+			return
+		}
+	}
+	for el := lirs.s.Back(); el != nil; el = el.Prev() {
+		entry := el.Value.(*lirsEntry)
+		if entry.lir && !f(entry.key, *lirs.lookup[entry.key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (lirs *LIRS) Pin(key string) bool {
+	if lirs.lookup[key] == nil {
+		return false
+	}
+	lirs.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (lirs *LIRS) Unpin(key string) bool {
+	return lirs.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (lirs *LIRS) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(lirs, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (lirs *LIRS) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(lirs, keys, values, func() { EvictLIRS(lirs) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (lirs *LIRS) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(lirs, keys)
+}
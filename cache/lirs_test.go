@@ -0,0 +1,158 @@
+/******************************************************************************
+ * lirs_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for lirs.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestLIRSSetGet(t *testing.T) {
+	capacity := 64
+	lirs := NewLIRS(capacity, 0.1)
+	checkCapacity(t, lirs, capacity)
+
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("key%d", i)
+		val := []byte(key)
+		ok := lirs.Set(key, val)
+		if !ok {
+			t.Errorf("Failed to add binding with key: %s", key)
+			t.FailNow()
+		}
+
+		res, _ := lirs.Get(key)
+		if !bytesEqual(res, val) {
+			t.Errorf("Wrong value %s for binding with key: %s", res, key)
+			t.FailNow()
+		}
+	}
+
+	// updating a key replaces its value
+	key := "key0"
+	val := []byte("updated")
+	ok := lirs.Set(key, val)
+	if !ok {
+		t.Errorf("Failed to update binding with key: %s", key)
+		t.FailNow()
+	}
+	res, _ := lirs.Get(key)
+	if !bytesEqual(res, val) {
+		t.Errorf("Wrong value %s for binding with key: %s", res, key)
+		t.FailNow()
+	}
+	rem := lirs.RemainingStorage()
+	if rem != capacity-4*8-len(val)+4 {
+		t.Errorf("Wrong remaining storage %d after update", rem)
+		t.FailNow()
+	}
+}
+
+func TestLIRSRemove(t *testing.T) {
+	capacity := 64
+	lirs := NewLIRS(capacity, 0.1)
+
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("key%d", i)
+		lirs.Set(key, []byte(key))
+	}
+
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("key%d", i)
+		val, ok := lirs.Remove(key)
+		if !ok {
+			t.Errorf("Failed to remove binding with key: %s", key)
+			t.FailNow()
+		}
+		if !bytesEqual(val, []byte(key)) {
+			t.Errorf("Wrong value %s for binding with key: %s", val, key)
+			t.FailNow()
+		}
+	}
+
+	if lirs.Len() != 0 || lirs.RemainingStorage() != capacity {
+		t.Errorf("LIRS should be empty but has length %d and remaining storage %d", lirs.Len(), lirs.RemainingStorage())
+		t.FailNow()
+	}
+	if lirs.Stats().Hits != 0 || lirs.Stats().Misses != 0 {
+		t.Errorf("Remove should not change stats")
+		t.FailNow()
+	}
+}
+
+func TestLIRSTooLarge(t *testing.T) {
+	capacity := 10
+	lirs := NewLIRS(capacity, 0.1)
+
+	key := "123456"
+	ok := lirs.Set(key, []byte(key))
+	if ok {
+		t.Errorf("Should have failed to add binding with key: %s", key)
+		t.FailNow()
+	}
+	if lirs.Len() != 0 || lirs.RemainingStorage() != capacity {
+		t.Errorf("Cache should be empty but has length %d", lirs.Len())
+	}
+}
+
+func TestLIRSNeverOverfills(t *testing.T) {
+	capacity := 100
+	lirs := NewLIRS(capacity, 0.2)
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("%d", (i*7)%37)
+		val := make([]byte, i%13)
+		if _, found := lirs.Get(key); !found {
+			lirs.Set(key, val)
+		}
+
+		if lirs.RemainingStorage() < 0 {
+			t.Errorf("Remaining storage went negative after %d accesses", i)
+			t.FailNow()
+		}
+		if lirs.lirSize > capacity || lirs.Len() != len(lirs.lookup) {
+			t.Errorf("Inconsistent LIRS state after %d accesses", i)
+			t.FailNow()
+		}
+	}
+}
+
+// A loop one entry larger than the cache never hits under LRU, but LIRS keeps
+// its LIR set resident and hits on it every pass.
+func TestLIRSLoop(t *testing.T) {
+	capacity := 100
+	lirs := NewLIRS(capacity, 0.1)
+	lru := NewLru(capacity)
+
+	for pass := 0; pass < 10; pass++ {
+		for i := 0; i < 11; i++ {
+			key := fmt.Sprintf("____%d", i)
+			val := []byte(key[:5])
+			if _, found := lirs.Get(key); !found {
+				lirs.Set(key, val)
+			}
+			if _, found := lru.Get(key); !found {
+				lru.Set(key, val)
+			}
+		}
+	}
+
+	if lru.Stats().Hits != 0 {
+		t.Errorf("LRU should never hit on a loop, but hit %d times", lru.Stats().Hits)
+		t.FailNow()
+	}
+	if lirs.Stats().Hits == 0 {
+		t.Errorf("LIRS should hit on a loop, but never did")
+		t.FailNow()
+	}
+}
@@ -2,7 +2,6 @@ package cache
 
 import (
 	"container/list"
-	"log"
 )
 
 // An LRU is a fixed-size in-memory cache with least-recently-used eviction
@@ -14,6 +13,8 @@ type LRU struct {
 	maxSize      int
 	currSize     int
 	stats        *Stats
+	onEvict      evictHandlers
+	pinned       pins
 }
 
 // NewLRU returns a pointer to a new LRU with a capacity to store limit bytes
@@ -27,6 +28,7 @@ func NewLru(limit int) *LRU {
 	cache.stats = new(Stats)
 	cache.stats.Hits = 0
 	cache.stats.Misses = 0
+	cache.pinned = pins{}
 	return cache
 }
 
@@ -40,6 +42,18 @@ func (lru *LRU) RemainingStorage() int {
 	return lru.maxSize - lru.currSize
 }
 
+// Resize changes the capacity of this LRU to limit bytes, evicting bindings
+// in the usual order until they fit.
+func (lru *LRU) Resize(limit int) {
+	if pinned := lru.pinned.size(lru.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	lru.maxSize = limit
+	for lru.currSize > lru.maxSize {
+		EvictLRU(lru)
+	}
+}
+
 // Get returns the value associated with the given key, if it exists.
 // This operation counts as a "use" for that key-value pair
 // ok is true if a value was found and false otherwise.
@@ -47,7 +61,7 @@ func (lru *LRU) Get(key string) (value []byte, ok bool) {
 	valPointer := lru.lookup[key]
 
 	if valPointer == nil {
-		lru.stats.Misses++
+		lru.stats.miss()
 		return nil, false
 	}
 
@@ -56,24 +70,22 @@ func (lru *LRU) Get(key string) (value []byte, ok bool) {
 	currEl := lru.stringToNode[key]
 	lru.q.MoveToFront(currEl)
 
-	lru.stats.Hits++
+	lru.stats.hit()
 	return *valPointer, true
 }
 
 // Remove removes and returns the value associated with the given key, if it exists.
 // ok is true if a value was found and false otherwise
 func (lru *LRU) Remove(key string) (value []byte, ok bool) {
-	prevHits, prevMisses := lru.stats.Hits, lru.stats.Misses
-	val, found := lru.Get(key)
-
-	// ensure no changes to stats with Get
-	lru.stats.Hits = prevHits
-	lru.stats.Misses = prevMisses
+	valPointer := lru.lookup[key]
 
-	if !found {
+	if valPointer == nil {
 		return nil, false
 	}
 
+	delete(lru.pinned, key)
+	val := *valPointer
+
 	delete(lru.lookup, key)
 
 	// remove matching element from queue
@@ -95,6 +107,11 @@ func (lru *LRU) Set(key string, value []byte) bool {
 		return false
 	}
 
+	// Pinned bindings can't be evicted to make room
+	if newElSize > lru.maxSize-lru.pinned.size(lru.lookup, key) {
+		return false
+	}
+
 	// Check to see if we're updating an existing key or adding a new key
 	existsInQ := false
 	existingVal := lru.lookup[key]
@@ -104,17 +121,19 @@ func (lru *LRU) Set(key string, value []byte) bool {
 		addedSize = len(value) - len(*existingVal)
 	}
 
+	// An updated key is the most recently used, so it's only at the back of
+	// the queue if it's the only key, when there's always room for it
+	if existsInQ {
+		lru.q.MoveToFront(lru.stringToNode[key])
+	}
+
 	// Evict until there's enough room
 	for lru.currSize+addedSize > lru.maxSize {
-		EvictLRU(lru, existsInQ)
+		EvictLRU(lru)
 	}
 
 	// Add new key:value pair
 	if existsInQ {
-		currEl := lru.stringToNode[key]
-		lru.q.Remove(currEl)
-		lru.q.PushFront(key)
-		lru.stringToNode[key] = lru.q.Front()
 		lru.currSize += addedSize
 		// Only add to the queue if it doesn't exist yet
 	} else {
@@ -129,31 +148,30 @@ func (lru *LRU) Set(key string, value []byte) bool {
 	return true
 }
 
-// Evict the last element added to list
-func EvictLRU(lru *LRU, existsInQ bool) {
+// Evict the least recently used binding that isn't pinned, if there is one
+func EvictLRU(lru *LRU) {
+	// Pinned bindings are skipped
 	backEl := lru.q.Back()
+	for backEl != nil && lru.pinned.pinned(backEl.Value.(string)) {
+		backEl = backEl.Prev()
+	}
+	// Nothing to evict
+	if backEl == nil {
+		return
+	}
 	remKey := backEl.Value.(string)
 
 	// Get the key:value pair from the map
 	valPointer := lru.lookup[remKey]
 
-	// Bad News: We're evicting a key that doesn't exist
-	if valPointer == nil {
-		log.Panic()
-	}
-
-	// Remove from map
+	// Remove from map and queue
 	delete(lru.lookup, remKey)
-
-	// remove from queue only if we're setting a new value
-	// Keep if we're updating a value
-	if !existsInQ {
-		delete(lru.stringToNode, remKey)
-		lru.q.Remove(backEl)
-	}
+	delete(lru.stringToNode, remKey)
+	lru.q.Remove(backEl)
 
 	// change size
 	lru.currSize -= len(remKey) + len(*valPointer)
+	lru.onEvict.evicted(remKey, *valPointer)
 }
 
 // Len returns the number of bindings in the LRU.
@@ -165,3 +183,69 @@ func (lru *LRU) Len() int {
 func (lru *LRU) Stats() *Stats {
 	return lru.stats
 }
+
+// OnEvict registers f to be called with each binding the LRU evicts.
+func (lru *LRU) OnEvict(f EvictFunc) {
+	lru.onEvict = append(lru.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (lru *LRU) Peek(key string) (value []byte, ok bool) {
+	valPointer := lru.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this LRU, without
+// counting as a use or changing Stats.
+func (lru *LRU) Contains(key string) bool {
+	return lru.lookup[key] != nil
+}
+
+// Range calls f with each binding in eviction order, least recently used
+// first, until f returns false. f must not use the LRU.
+func (lru *LRU) Range(f func(key string, value []byte) bool) {
+	for el := lru.q.Back(); el != nil; el = el.Prev() {
+		key := el.Value.(string)
+		if !f(key, *lru.lookup[key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (lru *LRU) Pin(key string) bool {
+	if lru.lookup[key] == nil {
+		return false
+	}
+	lru.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (lru *LRU) Unpin(key string) bool {
+	return lru.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (lru *LRU) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(lru, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (lru *LRU) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(lru, keys, values, func() { EvictLRU(lru) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (lru *LRU) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(lru, keys)
+}
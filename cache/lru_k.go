@@ -0,0 +1,391 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// DefaultK is the number of references LRU-K tracks when given k < 1
+const DefaultK = 2
+
+// lrukPartialOffset is subtracted from the priority of bindings with fewer than
+// K references so they are evicted before any binding with a full history, in
+// LRU order among themselves.
+const lrukPartialOffset = 1 << 52
+
+// lrukHistory is the reference history LRU-K keeps for a key, both while it is
+// resident and for a while after it is evicted.
+type lrukHistory struct {
+	key  string
+	hist []int // hist[i] is the time of the (i+1)-th most recent reference, 0 if unknown
+	last int   // time of the most recent reference, correlated or not
+
+	item     *Item         // nil if not resident
+	retained *list.Element // position in the retained list, nil if resident
+}
+
+// An LRUK is a fixed-size in-memory cache with LRU-K eviction. It evicts the
+// binding whose K-th most recent reference is oldest. References that follow
+// the previous one within the correlated reference period are treated as a
+// single burst, and histories of evicted keys are kept for the retained
+// information period so a key that comes back keeps its past.
+type LRUK struct {
+	pq       PriorityQueue
+	lookup   map[string]*[]byte
+	history  map[string]*lrukHistory
+	retained *list.List // histories of evicted keys, front is the oldest
+	maxSize  int
+	currSize int
+	stats    *Stats
+	onEvict  evictHandlers
+	pinned   pins
+
+	k                int
+	correlatedPeriod int
+	retainedPeriod   int
+	cacheAccesses    int
+}
+
+// NewLRUK returns a pointer to a new LRUK with a capacity to store limit bytes.
+// correlatedPeriod and retainedPeriod are measured in cache accesses.
+func NewLRUK(limit int, k int, correlatedPeriod int, retainedPeriod int) *LRUK {
+	cache := new(LRUK)
+
+	cache.lookup = map[string]*[]byte{}
+	cache.history = map[string]*lrukHistory{}
+	cache.retained = list.New()
+
+	cache.pq = make(PriorityQueue, 0)
+	heap.Init(&cache.pq)
+
+	cache.maxSize = limit
+	cache.currSize = 0
+	cache.stats = new(Stats)
+
+	if k < 1 {
+		k = DefaultK
+	}
+	cache.k = k
+	cache.correlatedPeriod = correlatedPeriod
+	cache.retainedPeriod = retainedPeriod
+	cache.cacheAccesses = 0
+	cache.pinned = pins{}
+	return cache
+}
+
+// MaxStorage returns the maximum number of bytes this LRUK can store
+func (lruk *LRUK) MaxStorage() int {
+	return lruk.maxSize
+}
+
+// RemainingStorage returns the number of unused bytes available in this LRUK
+func (lruk *LRUK) RemainingStorage() int {
+	return lruk.maxSize - lruk.currSize
+}
+
+// Resize changes the capacity of this LRUK to limit bytes, evicting bindings
+// in the usual order until they fit.
+func (lruk *LRUK) Resize(limit int) {
+	if pinned := lruk.pinned.size(lruk.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	lruk.maxSize = limit
+	for lruk.currSize > lruk.maxSize {
+		EvictLRUK(lruk)
+	}
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (lruk *LRUK) Get(key string) (value []byte, ok bool) {
+	lruk.cacheAccesses++
+	valPointer := lruk.lookup[key]
+
+	if valPointer == nil {
+		lruk.stats.miss()
+		return nil, false
+	}
+
+	h := lruk.history[key]
+	lruk.reference(h)
+	lruk.pq.Update(h.item, lruk.getLRUKPriority(h))
+
+	lruk.stats.hit()
+	return *valPointer, true
+}
+
+// reference records a reference to h at the current time. An uncorrelated
+// reference shifts the history by the length of the burst that just ended.
+func (lruk *LRUK) reference(h *lrukHistory) {
+	now := lruk.cacheAccesses
+	if h.hist[0] != 0 && now-h.last <= lruk.correlatedPeriod {
+		h.last = now
+		return
+	}
+
+	correlated := h.last - h.hist[0]
+	for i := lruk.k - 1; i > 0; i-- {
+		if h.hist[i-1] != 0 {
+			h.hist[i] = h.hist[i-1] + correlated
+		}
+	}
+	h.hist[0] = now
+	h.last = now
+}
+
+// priority = time of the K-th most recent reference
+func (lruk *LRUK) getLRUKPriority(h *lrukHistory) float64 {
+	kth := h.hist[lruk.k-1]
+	if kth == 0 {
+		return float64(h.last) - lrukPartialOffset
+	}
+	return float64(kth)
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (lruk *LRUK) Remove(key string) (value []byte, ok bool) {
+	valPointer := lruk.lookup[key]
+
+	if valPointer == nil {
+		return nil, false
+	}
+
+	delete(lruk.pinned, key)
+
+	delete(lruk.lookup, key)
+
+	// remove matching element from priority queue and forget its history
+	lruk.pq.Remove(lruk.history[key].item)
+	delete(lruk.history, key)
+
+	lruk.currSize -= len(key) + len(*valPointer)
+	return *valPointer, true
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+func (lruk *LRUK) Set(key string, value []byte) bool {
+	lruk.cacheAccesses++
+	lruk.forgetRetained()
+
+	// Check to see if too large for cache
+	newElSize := len(key) + len(value)
+	if newElSize > lruk.maxSize {
+		return false
+	}
+
+	// Pinned bindings can't be evicted to make room
+	if newElSize > lruk.maxSize-lruk.pinned.size(lruk.lookup, key) {
+		return false
+	}
+
+	// Updating a key replaces its value and counts as a reference
+	if existingVal := lruk.lookup[key]; existingVal != nil {
+		lruk.currSize += newElSize - (len(key) + len(*existingVal))
+		lruk.lookup[key] = &value
+
+		h := lruk.history[key]
+		lruk.reference(h)
+		lruk.pq.Update(h.item, lruk.getLRUKPriority(h))
+
+		// Evict until there's enough room, never evicting the updated key
+		for lruk.currSize > lruk.maxSize {
+			lruk.evict(h.item)
+		}
+		return true
+	}
+
+	// Evict until there's enough room
+	for lruk.currSize+newElSize > lruk.maxSize {
+		EvictLRUK(lruk)
+	}
+
+	// Bring back the retained history of a recently evicted key
+	h := lruk.history[key]
+	if h == nil {
+		h = &lrukHistory{
+			key:  key,
+			hist: make([]int, lruk.k),
+		}
+		lruk.history[key] = h
+	} else {
+		lruk.retained.Remove(h.retained)
+		h.retained = nil
+	}
+	lruk.reference(h)
+
+	h.item = &Item{
+		key:      key,
+		priority: lruk.getLRUKPriority(h),
+	}
+	heap.Push(&lruk.pq, h.item)
+	lruk.lookup[key] = &value
+	lruk.currSize += newElSize
+
+	return true
+}
+
+// evict removes the binding with the oldest K-th reference among those outside
+// their correlated reference period, falling back to the oldest overall if
+// every binding was referenced too recently. keep, if non-nil, and pinned
+// bindings are never evicted.
+//
+// It stops at the first binding it can evict and pushes back only the ones it
+// popped before it. Each access references one key, so at most
+// correlatedPeriod+1 bindings are in their correlated reference period, and an
+// eviction pops at most that many plus keep and the pinned bindings,
+// O((C+P) log n). In the worst case, a cache with no more bindings than that,
+// it pops the whole heap, O(n log n).
+func (lruk *LRUK) evict(keep *Item) {
+	now := lruk.cacheAccesses
+	skipped := []*Item{}
+	var victim *Item
+	for lruk.pq.Len() > 0 {
+		item := heap.Pop(&lruk.pq).(*Item)
+		if item != keep && !lruk.pinned.pinned(item.key) && now-lruk.history[item.key].last > lruk.correlatedPeriod {
+			victim = item
+			break
+		}
+		skipped = append(skipped, item)
+	}
+
+	for _, item := range skipped {
+		if victim == nil && item != keep && !lruk.pinned.pinned(item.key) {
+			victim = item
+			continue
+		}
+		heap.Push(&lruk.pq, item)
+	}
+
+	if victim == nil {
+		return
+	}
+	value := *(lruk.lookup[victim.key])
+	lruk.retain(victim)
+	lruk.onEvict.evicted(victim.key, value)
+}
+
+// Evict the binding with the oldest K-th reference outside its correlated
+// reference period
+func EvictLRUK(lruk *LRUK) {
+	lruk.evict(nil)
+}
+
+// retain drops the value of an item that has already been popped from the
+// priority queue but keeps its history.
+func (lruk *LRUK) retain(item *Item) {
+	key := item.key
+	value := *(lruk.lookup[key])
+	delete(lruk.lookup, key)
+	lruk.currSize -= len(key) + len(value)
+
+	h := lruk.history[key]
+	h.item = nil
+	h.retained = lruk.retained.PushBack(h)
+}
+
+// forgetRetained drops retained histories of evicted keys that have not been
+// referenced for the retained information period. Histories are dropped in
+// eviction order, so one may outlive the period by a little.
+func (lruk *LRUK) forgetRetained() {
+	for lruk.retained.Len() > 0 {
+		h := lruk.retained.Front().Value.(*lrukHistory)
+		if lruk.cacheAccesses-h.last <= lruk.retainedPeriod {
+			return
+		}
+		lruk.retained.Remove(h.retained)
+		delete(lruk.history, h.key)
+	}
+}
+
+// Len returns the number of bindings in the LRUK.
+func (lruk *LRUK) Len() int {
+	return lruk.pq.Len()
+}
+
+// Stats returns statistics about how many search hits and misses have occurred.
+func (lruk *LRUK) Stats() *Stats {
+	return lruk.stats
+}
+
+// OnEvict registers f to be called with each binding the LRUK evicts.
+func (lruk *LRUK) OnEvict(f EvictFunc) {
+	lruk.onEvict = append(lruk.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (lruk *LRUK) Peek(key string) (value []byte, ok bool) {
+	valPointer := lruk.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this LRUK, without
+// counting as a use or changing Stats.
+func (lruk *LRUK) Contains(key string) bool {
+	return lruk.lookup[key] != nil
+}
+
+// Range calls f with each binding in eviction order until f returns false:
+// oldest K-th reference first among bindings outside their correlated
+// reference period, then the others in the same order. Periods are counted
+// up to the next Set, which is when bindings are evicted. f must not use the
+// LRUK.
+func (lruk *LRUK) Range(f func(key string, value []byte) bool) {
+	now := lruk.cacheAccesses + 1
+	items := lruk.pq.sorted()
+	correlated := []*Item{}
+	for _, item := range items {
+		if now-lruk.history[item.key].last <= lruk.correlatedPeriod {
+			correlated = append(correlated, item)
+			continue
+		}
+		if !f(item.key, *lruk.lookup[item.key]) {
+			return
+		}
+	}
+	for _, item := range correlated {
+		if !f(item.key, *lruk.lookup[item.key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (lruk *LRUK) Pin(key string) bool {
+	if lruk.lookup[key] == nil {
+		return false
+	}
+	lruk.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (lruk *LRUK) Unpin(key string) bool {
+	return lruk.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (lruk *LRUK) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(lruk, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (lruk *LRUK) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(lruk, keys, values, func() { EvictLRUK(lruk) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (lruk *LRUK) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(lruk, keys)
+}
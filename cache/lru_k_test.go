@@ -0,0 +1,179 @@
+/******************************************************************************
+ * lru_k_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for lru_k.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestLRUKSetGet(t *testing.T) {
+	capacity := 64
+	lruk := NewLRUK(capacity, 2, 0, capacity)
+	checkCapacity(t, lruk, capacity)
+
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("key%d", i)
+		val := []byte(key)
+		ok := lruk.Set(key, val)
+		if !ok {
+			t.Errorf("Failed to add binding with key: %s", key)
+			t.FailNow()
+		}
+
+		res, _ := lruk.Get(key)
+		if !bytesEqual(res, val) {
+			t.Errorf("Wrong value %s for binding with key: %s", res, key)
+			t.FailNow()
+		}
+	}
+
+	// updating a key replaces its value
+	key := "key2"
+	val := []byte("a new value")
+	lruk.Set(key, val)
+	res, _ := lruk.Get(key)
+	if !bytesEqual(res, val) {
+		t.Errorf("Wrong value %s for binding with key: %s", res, key)
+		t.FailNow()
+	}
+	if lruk.RemainingStorage() != capacity-3*8-len(key)-len(val) {
+		t.Errorf("Wrong remaining storage %d after update", lruk.RemainingStorage())
+		t.FailNow()
+	}
+}
+
+func TestLRUKRemove(t *testing.T) {
+	capacity := 64
+	lruk := NewLRUK(capacity, 2, 0, capacity)
+
+	key := "Hello"
+	val := []byte("World")
+	lruk.Set(key, val)
+
+	res, ok := lruk.Remove(key)
+	if !ok || !bytesEqual(res, val) {
+		t.Errorf("Failed to remove binding with key: %s", key)
+		t.FailNow()
+	}
+	if lruk.Len() != 0 || lruk.RemainingStorage() != capacity {
+		t.Errorf("LRUK should be empty but has length %d", lruk.Len())
+		t.FailNow()
+	}
+	if lruk.Stats().Hits != 0 || lruk.Stats().Misses != 0 {
+		t.Errorf("Remove should not change stats")
+		t.FailNow()
+	}
+}
+
+func TestLRUKTooLarge(t *testing.T) {
+	capacity := 10
+	lruk := NewLRUK(capacity, 0, 0, 0)
+
+	key := "123456"
+	ok := lruk.Set(key, []byte(key))
+	if ok {
+		t.Errorf("Should have failed to add binding with key: %s", key)
+		t.FailNow()
+	}
+	if lruk.k != DefaultK {
+		t.Errorf("LRUK should default to K = %d but has K = %d", DefaultK, lruk.k)
+		t.FailNow()
+	}
+}
+
+// A binding referenced once is evicted before bindings referenced twice, even
+// though it was referenced most recently.
+func TestLRUKEvict(t *testing.T) {
+	capacity := 30
+	lruk := NewLRUK(capacity, 2, 0, capacity)
+
+	for _, key := range []string{"____a", "____b"} {
+		lruk.Set(key, []byte(key))
+		lruk.Get(key)
+	}
+	lruk.Set("____c", []byte("____c"))
+	lruk.Set("____d", []byte("____d"))
+
+	for _, key := range []string{"____a", "____b", "____d"} {
+		if _, found := lruk.Get(key); !found {
+			t.Errorf("Could not find binding with key: %s", key)
+			t.FailNow()
+		}
+	}
+	if _, found := lruk.Get("____c"); found {
+		t.Errorf("Should have evicted binding with key: ____c")
+		t.FailNow()
+	}
+}
+
+// References within the correlated reference period count as one.
+func TestLRUKCorrelatedPeriod(t *testing.T) {
+	for _, period := range []int{0, 5} {
+		capacity := 20
+		lruk := NewLRUK(capacity, 2, period, capacity)
+
+		// b is referenced twice, far apart
+		lruk.Set("____b", []byte("____b"))
+		for i := 0; i < 6; i++ {
+			lruk.Get("miss")
+		}
+		lruk.Get("____b")
+
+		// a is referenced three times in a burst
+		lruk.Set("____a", []byte("____a"))
+		lruk.Get("____a")
+		lruk.Get("____a")
+		for i := 0; i < 6; i++ {
+			lruk.Get("miss")
+		}
+
+		lruk.Set("____d", []byte("____d"))
+
+		// without a correlated period a looks referenced more recently than b
+		evicted := "____b"
+		if period > 0 {
+			evicted = "____a"
+		}
+		if _, found := lruk.Get(evicted); found {
+			t.Errorf("Should have evicted binding with key %s with correlated period %d", evicted, period)
+			t.FailNow()
+		}
+	}
+}
+
+func TestLRUKRetainedHistory(t *testing.T) {
+	for _, period := range []int{0, 100} {
+		capacity := 20
+		lruk := NewLRUK(capacity, 2, 0, period)
+
+		lruk.Set("____a", []byte("____a"))
+		lruk.Get("____a")
+		lruk.Set("____b", []byte("____b"))
+		lruk.Get("____b")
+
+		// evicts a, which has the oldest second reference
+		lruk.Set("____c", []byte("____c"))
+		if _, found := lruk.Get("____a"); found {
+			t.Errorf("Should have evicted binding with key: ____a")
+			t.FailNow()
+		}
+
+		lruk.Set("____a", []byte("____a"))
+		retained := lruk.history["____a"].hist[1] != 0
+		if retained != (period > 0) {
+			t.Errorf("History of ____a retained: %t, with retained period %d", retained, period)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// A NamespacedCache shares one pool of bytes between tenants, each with its
+// own keys, its own cache of the same policy and its own Stats. A tenant is
+// guaranteed a minimum number of bytes, which other tenants can't evict it
+// below or use while it doesn't, and can't use more than its maximum. When
+// the pool is full, room is taken from the tenant furthest over its minimum,
+// so one busy tenant can't evict everyone else.
+type NamespacedCache struct {
+	mu      sync.Mutex
+	budget  *MemoryBudget
+	factory func(limit int) BudgetMember
+	tenants map[string]*BudgetedCache
+}
+
+// NewNamespacedCache returns a NamespacedCache that shares limit bytes
+// between tenants, each of whose bindings are kept in a cache returned by
+// factory.
+func NewNamespacedCache(limit int, factory func(limit int) BudgetMember) *NamespacedCache {
+	nc := new(NamespacedCache)
+	nc.budget = NewMemoryBudget(limit, OverMinVictim)
+	nc.factory = factory
+	nc.tenants = map[string]*BudgetedCache{}
+	return nc
+}
+
+// AddTenant adds a tenant that is guaranteed min bytes and can use up to max,
+// or what other tenants' minimums leave of the pool if that is less. A max
+// that is not positive or is over the limit of the pool means the whole pool.
+// It fails if the tenant already exists, if min is over max, or if the
+// minimums of all tenants would be more than the pool.
+func (nc *NamespacedCache) AddTenant(tenant string, min int, max int) error {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+
+	limit := nc.budget.Limit()
+	if max <= 0 || max > limit {
+		max = limit
+	}
+	if nc.tenants[tenant] != nil {
+		return fmt.Errorf("cache: tenant %q already exists", tenant)
+	}
+	if min < 0 || min > max {
+		return fmt.Errorf("cache: tenant %q has minimum %d outside 0 to its maximum %d", tenant, min, max)
+	}
+
+	nc.budget.mu.Lock()
+	defer nc.budget.mu.Unlock()
+	if nc.budget.reserved+min > limit {
+		return fmt.Errorf("cache: minimum %d of tenant %q is more than the %d bytes of %d unreserved",
+			min, tenant, limit-nc.budget.reserved, limit)
+	}
+	nc.tenants[tenant] = nc.budget.register(nc.factory(0), 1, min, max)
+	return nil
+}
+
+// Tenant returns the cache holding tenant's bindings, or nil if there is no
+// such tenant. Its Stats count only the tenant's Gets, and its Usage shows
+// how much of the pool it has.
+func (nc *NamespacedCache) Tenant(tenant string) *BudgetedCache {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	return nc.tenants[tenant]
+}
+
+// Tenants returns the names of every tenant, sorted.
+func (nc *NamespacedCache) Tenants() []string {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	names := make([]string, 0, len(nc.tenants))
+	for name := range nc.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the value associated with the given key of tenant, if it exists.
+// ok is false if it doesn't or there is no such tenant.
+func (nc *NamespacedCache) Get(tenant string, key string) (value []byte, ok bool) {
+	bc := nc.Tenant(tenant)
+	if bc == nil {
+		return nil, false
+	}
+	return bc.Get(key)
+}
+
+// Remove removes and returns the value associated with the given key of
+// tenant, if it exists.
+func (nc *NamespacedCache) Remove(tenant string, key string) (value []byte, ok bool) {
+	bc := nc.Tenant(tenant)
+	if bc == nil {
+		return nil, false
+	}
+	return bc.Remove(key)
+}
+
+// Set associates the given value with the given key of tenant, possibly
+// evicting the tenant's or other tenants' values to make room. Returns false
+// if there is no such tenant or the binding is larger than its maximum.
+func (nc *NamespacedCache) Set(tenant string, key string, value []byte) bool {
+	bc := nc.Tenant(tenant)
+	if bc == nil {
+		return false
+	}
+	return bc.Set(key, value)
+}
+
+// GetMulti gets each of keys of tenant, returning the value of each and
+// whether it was found. The budget's lock is taken once for the batch.
+func (nc *NamespacedCache) GetMulti(tenant string, keys []string) (values [][]byte, found []bool) {
+	bc := nc.Tenant(tenant)
+	if bc == nil {
+		return make([][]byte, len(keys)), make([]bool, len(keys))
+	}
+	return bc.GetMulti(keys)
+}
+
+// SetMulti sets each of keys of tenant to the value at the same index, making
+// room in the pool for the whole batch at once, and returns whether each
+// binding was added. The budget's lock is taken once for the batch.
+func (nc *NamespacedCache) SetMulti(tenant string, keys []string, values [][]byte) []bool {
+	bc := nc.Tenant(tenant)
+	if bc == nil {
+		return make([]bool, len(keys))
+	}
+	return bc.SetMulti(keys, values)
+}
+
+// RemoveMulti removes each of keys of tenant, returning the value of each and
+// whether it was found. The budget's lock is taken once for the batch.
+func (nc *NamespacedCache) RemoveMulti(tenant string, keys []string) (values [][]byte, found []bool) {
+	bc := nc.Tenant(tenant)
+	if bc == nil {
+		return make([][]byte, len(keys)), make([]bool, len(keys))
+	}
+	return bc.RemoveMulti(keys)
+}
+
+// Used returns the number of bytes bindings take across all tenants.
+func (nc *NamespacedCache) Used() int {
+	return nc.budget.Used()
+}
+
+// Stats returns the hits and misses of every tenant added together.
+func (nc *NamespacedCache) Stats() *Stats {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	nc.budget.mu.Lock()
+	defer nc.budget.mu.Unlock()
+
+	total := new(Stats)
+	for _, bc := range nc.tenants {
+		stats := bc.cache.Stats()
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.ByteHits += stats.ByteHits
+		total.ByteMisses += stats.ByteMisses
+	}
+	return total
+}
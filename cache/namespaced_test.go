@@ -0,0 +1,182 @@
+/******************************************************************************
+ * namespaced_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    A testing suite for namespaced.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func newLruEvicter(limit int) BudgetMember {
+	return NewLru(limit)
+}
+
+// Tenants have separate keys and Stats.
+func TestNamespacedSeparate(t *testing.T) {
+	nc := NewNamespacedCache(100, newLruEvicter)
+	nc.AddTenant("a", 0, 0)
+	nc.AddTenant("b", 0, 0)
+
+	nc.Set("a", "key", []byte("from a"))
+	nc.Set("b", "key", []byte("from b"))
+	if value, _ := nc.Get("a", "key"); string(value) != "from a" {
+		t.Errorf("Tenant a got %q for its key", value)
+		t.FailNow()
+	}
+	nc.Get("b", "missing")
+	if nc.Set("c", "key", []byte("value")) {
+		t.Errorf("Set should fail for a tenant that wasn't added")
+		t.FailNow()
+	}
+
+	a, b := nc.Tenant("a").Stats(), nc.Tenant("b").Stats()
+	if a.Hits != 1 || a.Misses != 0 || b.Hits != 0 || b.Misses != 1 {
+		t.Errorf("Tenant a has stats %+v and b %+v", *a, *b)
+		t.FailNow()
+	}
+	if total := nc.Stats(); total.Hits != 1 || total.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss in total, got %+v", *total)
+		t.FailNow()
+	}
+	if fmt.Sprint(nc.Tenants()) != "[a b]" || nc.Used() != 2*len("key")+12 {
+		t.Errorf("Tenants are %v using %d bytes", nc.Tenants(), nc.Used())
+		t.FailNow()
+	}
+}
+
+// AddTenant rejects tenants whose quotas can't be met.
+func TestNamespacedAddTenant(t *testing.T) {
+	nc := NewNamespacedCache(100, newLruEvicter)
+	if err := nc.AddTenant("a", 60, 0); err != nil {
+		t.Errorf("Failed to add tenant a: %v", err)
+		t.FailNow()
+	}
+	for _, quota := range []struct {
+		tenant   string
+		min, max int
+	}{{"a", 0, 0}, {"b", 50, 0}, {"b", 30, 20}, {"b", -1, 0}} {
+		if err := nc.AddTenant(quota.tenant, quota.min, quota.max); err == nil {
+			t.Errorf("AddTenant(%q, %d, %d) should have failed", quota.tenant, quota.min, quota.max)
+			t.FailNow()
+		}
+	}
+}
+
+// Batches only use the tenant's own bindings, and fail for missing tenants.
+func TestNamespacedBatch(t *testing.T) {
+	nc := NewNamespacedCache(100, newLruEvicter)
+	nc.AddTenant("a", 0, 0)
+	nc.AddTenant("b", 0, 0)
+	keys := []string{"x", "y"}
+	if ok := nc.SetMulti("a", keys, [][]byte{[]byte("1"), []byte("2")}); fmt.Sprint(ok) != "[true true]" {
+		t.Errorf("SetMulti returned %v", ok)
+		t.FailNow()
+	}
+	if ok := nc.SetMulti("missing", keys, [][]byte{[]byte("1"), []byte("2")}); fmt.Sprint(ok) != "[false false]" {
+		t.Errorf("SetMulti for a missing tenant returned %v", ok)
+		t.FailNow()
+	}
+
+	if _, found := nc.GetMulti("b", keys); fmt.Sprint(found) != "[false false]" {
+		t.Errorf("GetMulti found %v of another tenant's keys", found)
+		t.FailNow()
+	}
+	values, found := nc.GetMulti("a", []string{"y", "x"})
+	if fmt.Sprint(found) != "[true true]" || string(values[0]) != "2" || string(values[1]) != "1" {
+		t.Errorf("GetMulti returned %q and %v", values, found)
+		t.FailNow()
+	}
+	if _, found := nc.RemoveMulti("missing", keys); fmt.Sprint(found) != "[false false]" {
+		t.Errorf("RemoveMulti for a missing tenant returned %v", found)
+		t.FailNow()
+	}
+	if _, found := nc.RemoveMulti("a", keys); fmt.Sprint(found) != "[true true]" || nc.Used() != 0 {
+		t.Errorf("RemoveMulti returned %v, leaving %d bytes used", found, nc.Used())
+		t.FailNow()
+	}
+}
+
+// A busy tenant can't evict another below its minimum, nor grow beyond its
+// maximum.
+func TestNamespacedQuotas(t *testing.T) {
+	nc := NewNamespacedCache(1000, newLruEvicter)
+	nc.AddTenant("quiet", 300, 0)
+	nc.AddTenant("capped", 0, 200)
+	nc.AddTenant("busy", 0, 0)
+
+	for i := 0; i < 40; i++ {
+		nc.Set("quiet", fmt.Sprintf("q%04d", i), make([]byte, 5))
+		nc.Set("capped", fmt.Sprintf("c%04d", i), make([]byte, 5))
+	}
+	for i := 0; i < 1000; i++ {
+		nc.Set("busy", fmt.Sprintf("b%04d", i), make([]byte, 5))
+		nc.Set("capped", fmt.Sprintf("c%04d", i), make([]byte, 5))
+	}
+
+	quiet, capped, busy := nc.Tenant("quiet").Usage(), nc.Tenant("capped").Usage(), nc.Tenant("busy").Usage()
+	// busy evicts its own bindings once it is as far over its minimum as
+	// quiet, which only ever used 400 bytes
+	if quiet.Used != 400 || capped.Used != 200 || busy.Used != 400 {
+		t.Errorf("Tenants use %d, %d and %d bytes, expected 400, 200 and 400", quiet.Used, capped.Used, busy.Used)
+		t.FailNow()
+	}
+	if nc.Set("capped", "large", make([]byte, 200)) {
+		t.Errorf("Set should reject a binding larger than the tenant's maximum")
+		t.FailNow()
+	}
+}
+
+// Room is taken from the tenant furthest over its minimum.
+func TestNamespacedOverMin(t *testing.T) {
+	nc := NewNamespacedCache(1000, newLruEvicter)
+	nc.AddTenant("a", 400, 0)
+	nc.AddTenant("b", 100, 0)
+	for i := 0; i < 100; i++ {
+		nc.Set("a", fmt.Sprintf("a%04d", i), make([]byte, 5))
+	}
+
+	// a is 600 bytes over its minimum, so b takes room from it until they are
+	// about as far over theirs, to within the bindings moved on a tie
+	for i := 0; i < 100; i++ {
+		nc.Set("b", fmt.Sprintf("b%04d", i), make([]byte, 5))
+	}
+	a, b := nc.Tenant("a").Usage(), nc.Tenant("b").Usage()
+	if diff := (a.Used - a.Min) - (b.Used - b.Min); diff < -20 || diff > 20 || a.Used+b.Used != 1000 {
+		t.Errorf("Tenants use %d and %d bytes, expected them to be as far over their minimums", a.Used, b.Used)
+		t.FailNow()
+	}
+}
+
+// Room a tenant is guaranteed stays free for it while it doesn't use it.
+func TestNamespacedReserved(t *testing.T) {
+	nc := NewNamespacedCache(1000, newLruEvicter)
+	nc.AddTenant("idle", 300, 0)
+	nc.AddTenant("busy", 0, 0)
+	for i := 0; i < 1000; i++ {
+		nc.Set("busy", fmt.Sprintf("b%04d", i), make([]byte, 5))
+	}
+
+	busy := nc.Tenant("busy")
+	if busy.MaxStorage() != 700 || busy.Usage().Used != 700 {
+		t.Errorf("busy can store %d bytes and uses %d, expected 700 of them", busy.MaxStorage(), busy.Usage().Used)
+		t.FailNow()
+	}
+	for i := 0; i < 30; i++ {
+		nc.Set("idle", fmt.Sprintf("i%04d", i), make([]byte, 5))
+	}
+	if nc.Tenant("idle").Len() != 30 || busy.Usage().Used != 700 {
+		t.Errorf("idle holds %d bindings and busy uses %d bytes, expected 30 and 700",
+			nc.Tenant("idle").Len(), busy.Usage().Used)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,39 @@
+package cache
+
+// pins counts how many times each pinned key of a cache has been pinned.
+// Pinned bindings are never evicted, though they can still be removed.
+type pins map[string]int
+
+// pin pins key once more.
+func (p pins) pin(key string) {
+	p[key]++
+}
+
+// unpin undoes one pin of key, and returns false if key wasn't pinned.
+func (p pins) unpin(key string) bool {
+	if p[key] == 0 {
+		return false
+	}
+	p[key]--
+	if p[key] == 0 {
+		delete(p, key)
+	}
+	return true
+}
+
+// pinned returns whether key is pinned.
+func (p pins) pinned(key string) bool {
+	return p[key] > 0
+}
+
+// size returns the number of bytes the pinned bindings in lookup take, other
+// than the one for except.
+func (p pins) size(lookup map[string]*[]byte, except string) int {
+	size := 0
+	for key := range p {
+		if key != except {
+			size += len(key) + len(*lookup[key])
+		}
+	}
+	return size
+}
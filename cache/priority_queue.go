@@ -5,6 +5,7 @@ package cache
 import (
 	"container/heap"
 	"fmt"
+	"sort"
 )
 
 // An Item is something we manage in a priority queue.
@@ -78,6 +79,34 @@ func (pq *PriorityQueue) Update(item *Item, priority float64) {
 	// fmt.Println()
 }
 
+// sorted returns the items in the queue in the order Pop would return them,
+// without changing the queue.
+func (pq PriorityQueue) sorted() []*Item {
+	items := make([]*Item, len(pq))
+	copy(items, pq)
+	sort.SliceStable(items, func(i, j int) bool { return items[i].priority < items[j].priority })
+	return items
+}
+
+// popUnless pops items until it finds one skip returns false for, pushes the
+// others back and returns that item, or nil if there is none.
+func (pq *PriorityQueue) popUnless(skip func(item *Item) bool) *Item {
+	skipped := []*Item{}
+	var found *Item
+	for pq.Len() > 0 {
+		item := heap.Pop(pq).(*Item)
+		if !skip(item) {
+			found = item
+			break
+		}
+		skipped = append(skipped, item)
+	}
+	for _, item := range skipped {
+		heap.Push(pq, item)
+	}
+	return found
+}
+
 // This example creates a PriorityQueue with some items, adds and manipulates an item,
 // and then removes the items in priority order.
 func main() {
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"strings"
+)
+
+// radixNode is a node of a radixTree. The key of a node is the labels of the
+// edges from the root to it.
+type radixNode struct {
+	label    string // label of the edge from the parent
+	children map[byte]*radixNode
+	leaf     bool // whether the key of this node is in the tree
+}
+
+// A radixTree is a set of strings, compressed so that every node other than
+// the root is a key or has more than one child. It finds every key with a
+// prefix without looking at any other keys.
+type radixTree struct {
+	root *radixNode
+	size int
+}
+
+func newRadixTree() *radixTree {
+	return &radixTree{root: &radixNode{children: map[byte]*radixNode{}}}
+}
+
+// insert adds key to the tree.
+func (tree *radixTree) insert(key string) {
+	n := tree.root
+	for key != "" {
+		child := n.children[key[0]]
+		if child == nil {
+			n.children[key[0]] = &radixNode{label: key, children: map[byte]*radixNode{}, leaf: true}
+			tree.size++
+			return
+		}
+
+		common := commonPrefix(child.label, key)
+		if common < len(child.label) {
+			// split the edge where key leaves it
+			mid := &radixNode{label: child.label[:common], children: map[byte]*radixNode{}}
+			child.label = child.label[common:]
+			mid.children[child.label[0]] = child
+			n.children[key[0]] = mid
+			child = mid
+		}
+		n = child
+		key = key[common:]
+	}
+	if !n.leaf {
+		n.leaf = true
+		tree.size++
+	}
+}
+
+// remove removes key from the tree, if it is in it.
+func (tree *radixTree) remove(key string) {
+	var parent *radixNode
+	n := tree.root
+	for key != "" {
+		child := n.children[key[0]]
+		if child == nil || !strings.HasPrefix(key, child.label) {
+			return
+		}
+		parent, n = n, child
+		key = key[len(child.label):]
+	}
+	if !n.leaf {
+		return
+	}
+	n.leaf = false
+	tree.size--
+	if n == tree.root {
+		return
+	}
+
+	// keep the tree compressed
+	if len(n.children) == 0 {
+		delete(parent.children, n.label[0])
+		if parent != tree.root && !parent.leaf && len(parent.children) == 1 {
+			parent.mergeChild()
+		}
+	} else if len(n.children) == 1 {
+		n.mergeChild()
+	}
+}
+
+// mergeChild merges the only child of n into it.
+func (n *radixNode) mergeChild() {
+	for _, child := range n.children {
+		n.label += child.label
+		n.children = child.children
+		n.leaf = child.leaf
+	}
+}
+
+// withPrefix returns every key in the tree that starts with prefix.
+func (tree *radixTree) withPrefix(prefix string) []string {
+	n := tree.root
+	path := ""
+	for prefix != "" {
+		child := n.children[prefix[0]]
+		if child == nil {
+			return nil
+		}
+		if strings.HasPrefix(prefix, child.label) {
+			prefix = prefix[len(child.label):]
+		} else if strings.HasPrefix(child.label, prefix) {
+			prefix = ""
+		} else {
+			return nil
+		}
+		path += child.label
+		n = child
+	}
+
+	keys := []string{}
+	n.collect(path, &keys)
+	return keys
+}
+
+// collect appends the keys of n and its descendants to keys, where path is
+// the key of n.
+func (n *radixNode) collect(path string, keys *[]string) {
+	if n.leaf {
+		*keys = append(*keys, path)
+	}
+	for _, child := range n.children {
+		child.collect(path+child.label, keys)
+	}
+}
+
+// commonPrefix returns the length of the longest common prefix of a and b.
+func commonPrefix(a string, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
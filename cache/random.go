@@ -0,0 +1,326 @@
+package cache
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// EntryInfo describes a binding to a PriorityFunc.
+type EntryInfo struct {
+	Key        string
+	Size       int // bytes taken up by the key and value
+	Accesses   int // number of Sets and Gets of the key since it was added
+	Inserted   int // cache access at which the key was added
+	LastAccess int // cache access at which the key was last used
+}
+
+// A PriorityFunc returns the priority of a binding at cache access now.
+// Sampled eviction evicts the sampled binding with the lowest priority.
+type PriorityFunc func(info EntryInfo, now int) float64
+
+// SampledLRU approximates LRU: the least recently used sample is evicted.
+func SampledLRU(info EntryInfo, now int) float64 {
+	return float64(info.LastAccess)
+}
+
+// SampledLFU approximates LFU: the least frequently used sample is evicted.
+func SampledLFU(info EntryInfo, now int) float64 {
+	return float64(info.Accesses)
+}
+
+// randomEntry is a binding and the bookkeeping sampled eviction needs.
+type randomEntry struct {
+	info  EntryInfo
+	index int // position in keys
+}
+
+// A Random is a fixed-size in-memory cache with random eviction. With more
+// than one sample it evicts, like Redis, the lowest priority binding among a
+// few chosen at random, so any PriorityFunc can be used without a heap.
+type Random struct {
+	lookup   map[string]*[]byte
+	entries  map[string]*randomEntry
+	keys     []*randomEntry
+	maxSize  int
+	currSize int
+	stats    *Stats
+	onEvict  evictHandlers
+	pinned   pins
+
+	samples       int
+	priority      PriorityFunc
+	rand          *rand.Rand
+	cacheAccesses int
+}
+
+// NewRandom returns a pointer to a new Random with a capacity to store limit
+// bytes that evicts a binding chosen uniformly at random, seeded with seed.
+func NewRandom(limit int, seed int64) *Random {
+	return NewSampled(limit, 1, SampledLRU, seed)
+}
+
+// NewSampled returns a pointer to a new Random with a capacity to store limit
+// bytes that evicts the binding with the lowest priority among samples chosen
+// at random, seeded with seed.
+func NewSampled(limit int, samples int, priority PriorityFunc, seed int64) *Random {
+	cache := new(Random)
+	cache.lookup = map[string]*[]byte{}
+	cache.entries = map[string]*randomEntry{}
+	cache.keys = []*randomEntry{}
+	cache.maxSize = limit
+	cache.currSize = 0
+	cache.stats = new(Stats)
+
+	if samples < 1 {
+		samples = 1
+	}
+	cache.samples = samples
+	cache.priority = priority
+	cache.rand = rand.New(rand.NewSource(seed))
+	cache.cacheAccesses = 0
+	cache.pinned = pins{}
+	return cache
+}
+
+// MaxStorage returns the maximum number of bytes this Random can store
+func (random *Random) MaxStorage() int {
+	return random.maxSize
+}
+
+// RemainingStorage returns the number of unused bytes available in this Random
+func (random *Random) RemainingStorage() int {
+	return random.maxSize - random.currSize
+}
+
+// Resize changes the capacity of this Random to limit bytes, evicting bindings
+// in the usual order until they fit.
+func (random *Random) Resize(limit int) {
+	if pinned := random.pinned.size(random.lookup, ""); limit < pinned {
+		limit = pinned
+	}
+	random.maxSize = limit
+	for random.currSize > random.maxSize {
+		EvictRandom(random)
+	}
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (random *Random) Get(key string) (value []byte, ok bool) {
+	random.cacheAccesses++
+	valPointer := random.lookup[key]
+
+	if valPointer == nil {
+		random.stats.miss()
+		return nil, false
+	}
+
+	random.use(random.entries[key])
+
+	random.stats.hit()
+	return *valPointer, true
+}
+
+func (random *Random) use(entry *randomEntry) {
+	entry.info.Accesses++
+	entry.info.LastAccess = random.cacheAccesses
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (random *Random) Remove(key string) (value []byte, ok bool) {
+	valPointer := random.lookup[key]
+
+	if valPointer == nil {
+		return nil, false
+	}
+
+	delete(random.pinned, key)
+
+	random.drop(random.entries[key])
+	return *valPointer, true
+}
+
+// drop removes entry from the lookup tables and the key list, swapping the
+// last key into its place.
+func (random *Random) drop(entry *randomEntry) {
+	last := random.keys[len(random.keys)-1]
+	random.keys[entry.index] = last
+	last.index = entry.index
+	random.keys[len(random.keys)-1] = nil
+	random.keys = random.keys[:len(random.keys)-1]
+
+	delete(random.lookup, entry.info.Key)
+	delete(random.entries, entry.info.Key)
+	random.currSize -= entry.info.Size
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+func (random *Random) Set(key string, value []byte) bool {
+	random.cacheAccesses++
+
+	// Check to see if too large for cache
+	newElSize := len(key) + len(value)
+	if newElSize > random.maxSize {
+		return false
+	}
+
+	// Pinned bindings can't be evicted to make room
+	if newElSize > random.maxSize-random.pinned.size(random.lookup, key) {
+		return false
+	}
+
+	// Updating a key replaces its value and counts as a use
+	if entry := random.entries[key]; entry != nil {
+		random.currSize += newElSize - entry.info.Size
+		entry.info.Size = newElSize
+		random.lookup[key] = &value
+		random.use(entry)
+
+		// Evict until there's enough room, never evicting the updated key
+		for random.currSize > random.maxSize {
+			random.evict(entry)
+		}
+		return true
+	}
+
+	// Evict until there's enough room
+	for random.currSize+newElSize > random.maxSize {
+		EvictRandom(random)
+	}
+
+	entry := &randomEntry{
+		info: EntryInfo{
+			Key:        key,
+			Size:       newElSize,
+			Accesses:   1,
+			Inserted:   random.cacheAccesses,
+			LastAccess: random.cacheAccesses,
+		},
+		index: len(random.keys),
+	}
+	random.keys = append(random.keys, entry)
+	random.entries[key] = entry
+	random.lookup[key] = &value
+	random.currSize += newElSize
+	return true
+}
+
+// evict samples bindings other than keep and pinned ones at random and
+// removes the one with the lowest priority.
+func (random *Random) evict(keep *randomEntry) {
+	candidates := len(random.keys) - len(random.pinned)
+	if keep != nil && !random.pinned.pinned(keep.info.Key) {
+		candidates--
+	}
+	if candidates <= 0 {
+		return
+	}
+
+	var victim *randomEntry
+	victimPriority := 0.0
+	for i := 0; i < random.samples || victim == nil; i++ {
+		entry := random.keys[random.rand.Intn(len(random.keys))]
+		if entry == keep || random.pinned.pinned(entry.info.Key) {
+			continue
+		}
+		priority := random.priority(entry.info, random.cacheAccesses)
+		if victim == nil || priority < victimPriority {
+			victim = entry
+			victimPriority = priority
+		}
+	}
+	value := *(random.lookup[victim.info.Key])
+	random.drop(victim)
+	random.onEvict.evicted(victim.info.Key, value)
+}
+
+// Evict the lowest priority binding among a random sample
+func EvictRandom(random *Random) {
+	random.evict(nil)
+}
+
+// Len returns the number of bindings in the Random.
+func (random *Random) Len() int {
+	return len(random.keys)
+}
+
+// Stats returns statistics about how many search hits and misses have occurred.
+func (random *Random) Stats() *Stats {
+	return random.stats
+}
+
+// OnEvict registers f to be called with each binding the Random evicts.
+func (random *Random) OnEvict(f EvictFunc) {
+	random.onEvict = append(random.onEvict, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats.
+func (random *Random) Peek(key string) (value []byte, ok bool) {
+	valPointer := random.lookup[key]
+	if valPointer == nil {
+		return nil, false
+	}
+	return *valPointer, true
+}
+
+// Contains returns true if the given key is bound in this Random, without
+// counting as a use or changing Stats.
+func (random *Random) Contains(key string) bool {
+	return random.lookup[key] != nil
+}
+
+// Range calls f with each binding, lowest priority first, until f returns
+// false. Eviction picks the lowest priority binding of a random sample, so
+// this is only the order bindings are likely to be evicted in; with a single
+// sample every binding is as likely as any other. f must not use the Random.
+func (random *Random) Range(f func(key string, value []byte) bool) {
+	entries := make([]*randomEntry, len(random.keys))
+	copy(entries, random.keys)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return random.priority(entries[i].info, random.cacheAccesses) <
+			random.priority(entries[j].info, random.cacheAccesses)
+	})
+	for _, entry := range entries {
+		if !f(entry.info.Key, *random.lookup[entry.info.Key]) {
+			return
+		}
+	}
+}
+
+// Pin keeps the binding for key from being evicted until it has been unpinned
+// as many times as it was pinned. Returns false if key isn't bound.
+func (random *Random) Pin(key string) bool {
+	if random.lookup[key] == nil {
+		return false
+	}
+	random.pinned.pin(key)
+	return true
+}
+
+// Unpin undoes one Pin of key. Returns false if key isn't pinned.
+func (random *Random) Unpin(key string) bool {
+	return random.pinned.unpin(key)
+}
+
+// GetMulti gets each of keys as Get does, returning the value of each and
+// whether it was found.
+func (random *Random) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return getMulti(random, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does,
+// evicting once up front for the bytes the whole batch adds, and returns
+// whether each binding was added.
+func (random *Random) SetMulti(keys []string, values [][]byte) []bool {
+	return setMulti(random, keys, values, func() { EvictRandom(random) })
+}
+
+// RemoveMulti removes each of keys as Remove does, returning the value of
+// each and whether it was found.
+func (random *Random) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	return removeMulti(random, keys)
+}
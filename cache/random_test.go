@@ -0,0 +1,131 @@
+/******************************************************************************
+ * random_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for random.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestRandomSetGet(t *testing.T) {
+	capacity := 64
+	random := NewRandom(capacity, 1)
+	checkCapacity(t, random, capacity)
+
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("key%d", i)
+		val := []byte(key)
+		ok := random.Set(key, val)
+		if !ok {
+			t.Errorf("Failed to add binding with key: %s", key)
+			t.FailNow()
+		}
+
+		res, _ := random.Get(key)
+		if !bytesEqual(res, val) {
+			t.Errorf("Wrong value %s for binding with key: %s", res, key)
+			t.FailNow()
+		}
+	}
+
+	// updating a key replaces its value
+	key := "key0"
+	val := []byte("a new value")
+	random.Set(key, val)
+	res, _ := random.Get(key)
+	if !bytesEqual(res, val) {
+		t.Errorf("Wrong value %s for binding with key: %s", res, key)
+		t.FailNow()
+	}
+}
+
+func TestRandomRemove(t *testing.T) {
+	capacity := 64
+	random := NewRandom(capacity, 1)
+
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("key%d", i)
+		random.Set(key, []byte(key))
+	}
+	for i := 3; i >= 0; i-- {
+		key := fmt.Sprintf("key%d", i)
+		res, ok := random.Remove(key)
+		if !ok || !bytesEqual(res, []byte(key)) {
+			t.Errorf("Failed to remove binding with key: %s", key)
+			t.FailNow()
+		}
+	}
+	if random.Len() != 0 || random.RemainingStorage() != capacity {
+		t.Errorf("Random should be empty but has length %d", random.Len())
+		t.FailNow()
+	}
+}
+
+func TestRandomTooLarge(t *testing.T) {
+	capacity := 10
+	random := NewRandom(capacity, 1)
+
+	key := "123456"
+	ok := random.Set(key, []byte(key))
+	if ok {
+		t.Errorf("Should have failed to add binding with key: %s", key)
+		t.FailNow()
+	}
+}
+
+// The same seed gives the same evictions.
+func TestRandomSeeded(t *testing.T) {
+	first := NewRandom(100, 42)
+	second := NewRandom(100, 42)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("%05d", (i*31)%97)
+		for _, random := range []*Random{first, second} {
+			if _, found := random.Get(key); !found {
+				random.Set(key, []byte(key))
+			}
+			if random.RemainingStorage() < 0 {
+				t.Errorf("Remaining storage went negative after %d accesses", i)
+				t.FailNow()
+			}
+		}
+	}
+
+	if !first.Stats().Equals(second.Stats()) {
+		t.Errorf("Caches with the same seed have different stats")
+		t.FailNow()
+	}
+}
+
+// Sampling every binding makes sampled eviction exact.
+func TestSampledLFU(t *testing.T) {
+	capacity := 100
+	sampled := NewSampled(capacity, 1000, SampledLFU, 1)
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("____%d", i)
+		sampled.Set(key, []byte(key))
+		if i != 4 {
+			sampled.Get(key)
+		}
+	}
+	sampled.Set("___10", []byte("___10"))
+
+	if _, found := sampled.Get("____4"); found {
+		t.Errorf("Should have evicted binding with key: ____4")
+		t.FailNow()
+	}
+	if sampled.Len() != 10 {
+		t.Errorf("Sampled should have length 10 but has length %d", sampled.Len())
+		t.FailNow()
+	}
+}
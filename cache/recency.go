@@ -0,0 +1,112 @@
+package cache
+
+// A recency orders key hashes by when they were last added, and sums the
+// sizes of those added since any one of them, in O(log n). Each addition
+// takes the next stamp; the sizes are kept in a Fenwick tree indexed by
+// stamp, and stamps are renumbered when they run out.
+type recency struct {
+	entries []*sizingEntry // by stamp, nil where removed; entries[0] is unused
+	tree    []int          // Fenwick tree of the sizes in entries
+	stamps  map[uint64]int
+	next    int // stamp of the next addition
+	oldest  int // no entry has a lower stamp
+	total   int // sum of sizes
+}
+
+// newRecency returns an empty recency.
+func newRecency() *recency {
+	r := new(recency)
+	r.stamps = map[uint64]int{}
+	r.renumber()
+	return r
+}
+
+// len returns the number of hashes in r.
+func (r *recency) len() int {
+	return len(r.stamps)
+}
+
+// contains returns true if hash is in r.
+func (r *recency) contains(hash uint64) bool {
+	_, ok := r.stamps[hash]
+	return ok
+}
+
+// add makes hash, with a binding of size bytes, the most recent in r.
+func (r *recency) add(hash uint64, size int) {
+	r.remove(hash)
+	if r.next == len(r.entries) {
+		r.renumber()
+	}
+	stamp := r.next
+	r.next++
+	r.entries[stamp] = &sizingEntry{hash, size}
+	r.stamps[hash] = stamp
+	r.update(stamp, size)
+	r.total += size
+}
+
+// remove removes hash from r, and returns whether it was there.
+func (r *recency) remove(hash uint64) bool {
+	stamp, ok := r.stamps[hash]
+	if !ok {
+		return false
+	}
+	size := r.entries[stamp].size
+	r.update(stamp, -size)
+	r.total -= size
+	r.entries[stamp] = nil
+	delete(r.stamps, hash)
+	return true
+}
+
+// since returns the bytes of hash and of every hash added after it.
+func (r *recency) since(hash uint64) int {
+	return r.total - r.prefix(r.stamps[hash]-1)
+}
+
+// last returns the least recent entry in r, which must not be empty.
+func (r *recency) last() *sizingEntry {
+	for r.entries[r.oldest] == nil {
+		r.oldest++
+	}
+	return r.entries[r.oldest]
+}
+
+// update adds delta to the size at stamp in the Fenwick tree.
+func (r *recency) update(stamp int, delta int) {
+	for i := stamp; i < len(r.tree); i += i & -i {
+		r.tree[i] += delta
+	}
+}
+
+// prefix returns the sum of the sizes at stamps up to and including stamp.
+func (r *recency) prefix(stamp int) int {
+	sum := 0
+	for i := stamp; i > 0; i -= i & -i {
+		sum += r.tree[i]
+	}
+	return sum
+}
+
+// renumber gives the entries stamps from 1 in the same order, leaving room
+// for as many additions again, so that renumbering takes O(log n) amortized
+// over the additions between renumberings.
+func (r *recency) renumber() {
+	live := make([]*sizingEntry, 0, len(r.stamps))
+	for _, entry := range r.entries {
+		if entry != nil {
+			live = append(live, entry)
+		}
+	}
+	n := 2*len(live) + 16
+	r.entries = make([]*sizingEntry, n)
+	r.tree = make([]int, n)
+	for i, entry := range live {
+		r.entries[i+1] = entry
+		r.stamps[entry.hash] = i + 1
+		r.update(i+1, entry.size)
+	}
+	r.next = len(live) + 1
+	r.oldest = 1
+}
@@ -0,0 +1,77 @@
+/******************************************************************************
+ * recency_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for recency.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"math/rand"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// A recency agrees with a list kept in order of use, across many
+// renumberings.
+func TestRecency(t *testing.T) {
+	r := newRecency()
+	order := []sizingEntry{} // most recent last
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		hash := uint64(rng.Intn(100))
+		at := listIndex(order, hash)
+		if rng.Intn(4) == 0 {
+			if r.remove(hash) != (at >= 0) {
+				t.Errorf("remove(%d) disagrees with the list on whether it was there", hash)
+				t.FailNow()
+			}
+			if at >= 0 {
+				order = append(order[:at], order[at+1:]...)
+			}
+		} else {
+			if at >= 0 {
+				want := 0
+				for _, entry := range order[at:] {
+					want += entry.size
+				}
+				if got := r.since(hash); got != want {
+					t.Errorf("since(%d) is %d, expected %d", hash, got, want)
+					t.FailNow()
+				}
+				order = append(order[:at], order[at+1:]...)
+			}
+			size := rng.Intn(50)
+			r.add(hash, size)
+			order = append(order, sizingEntry{hash, size})
+		}
+
+		if r.len() != len(order) {
+			t.Errorf("After %d operations recency holds %d hashes, expected %d", i+1, r.len(), len(order))
+			t.FailNow()
+		}
+		if len(order) > 0 && r.last().hash != order[0].hash {
+			t.Errorf("After %d operations %d is least recent, expected %d", i+1, r.last().hash, order[0].hash)
+			t.FailNow()
+		}
+	}
+}
+
+/******************************************************************************/
+/*                                 Helpers                                    */
+/******************************************************************************/
+
+// Returns the index of hash in order, or -1 if it isn't there.
+func listIndex(order []sizingEntry, hash uint64) int {
+	for i, entry := range order {
+		if entry.hash == hash {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,251 @@
+package cache
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"math"
+	"time"
+
+	"cos316.princeton.edu/assignment3/trace"
+)
+
+// RecordingOptions control what a RecordingCache writes to its trace.
+type RecordingOptions struct {
+	// SampleRate is the fraction of keys whose accesses are recorded. Keys
+	// are sampled by hash, so every access to a sampled key is kept and the
+	// trace can still be replayed. Zero or one records every key.
+	SampleRate float64
+
+	// Salt, if set, anonymizes keys with a keyed hash so recorded hashes
+	// can't be matched against guessed keys. Without it keys are hashed
+	// with 64-bit FNV-1a and a final mixing step.
+	Salt []byte
+
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+}
+
+// A RecordingCache wraps a Cache and writes every Get, Set and Remove to a
+// binary trace that the trace package can read back and replay.
+type RecordingCache struct {
+	cache   Cache
+	w       *trace.RecordWriter
+	options RecordingOptions
+	sampled uint64 // largest key hash that is recorded
+	err     error
+}
+
+// NewRecordingCache returns a RecordingCache that passes operations through to
+// cache and records them to w.
+func NewRecordingCache(cache Cache, w io.Writer, options RecordingOptions) (*RecordingCache, error) {
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+
+	rc := new(RecordingCache)
+	rc.cache = cache
+	rc.options = options
+	rc.sampled = math.MaxUint64
+	if options.SampleRate > 0 && options.SampleRate < 1 {
+		rc.sampled = uint64(options.SampleRate * math.MaxUint64)
+	}
+
+	var err error
+	rc.w, err = trace.NewRecordWriter(w, options.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// HashKey returns the hash a RecordingCache records for key.
+func (rc *RecordingCache) HashKey(key string) uint64 {
+	if len(rc.options.Salt) > 0 {
+		mac := hmac.New(sha256.New, rc.options.Salt)
+		mac.Write([]byte(key))
+		return binary.LittleEndian.Uint64(mac.Sum(nil))
+	}
+	return hashKey(key)
+}
+
+// hashKey returns an unsalted 64-bit hash of key.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return mix64(h.Sum64())
+}
+
+// mix64 spreads FNV's output over all 64 bits so that similar keys don't have
+// similar hashes, which would skew sampling.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// record writes an operation on key to the trace if key is sampled. The first
+// write error stops recording and is reported by Err.
+func (rc *RecordingCache) record(op trace.Op, key string, size int, hit bool) {
+	if rc.err != nil {
+		return
+	}
+	hash := rc.HashKey(key)
+	if hash > rc.sampled {
+		return
+	}
+	rc.err = rc.w.Write(trace.Record{
+		Time:    rc.options.Now().UnixNano(),
+		KeyHash: hash,
+		Size:    size,
+		Op:      op,
+		Hit:     hit,
+	})
+}
+
+// Flush writes any buffered records to the trace.
+func (rc *RecordingCache) Flush() error {
+	if rc.err != nil {
+		return rc.err
+	}
+	rc.err = rc.w.Flush()
+	return rc.err
+}
+
+// Err returns the first error writing the trace, if any.
+func (rc *RecordingCache) Err() error {
+	return rc.err
+}
+
+// MaxStorage returns the maximum number of bytes the wrapped cache can store
+func (rc *RecordingCache) MaxStorage() int {
+	return rc.cache.MaxStorage()
+}
+
+// RemainingStorage returns the number of unused bytes available in the wrapped cache
+func (rc *RecordingCache) RemainingStorage() int {
+	return rc.cache.RemainingStorage()
+}
+
+// Get returns the value associated with the given key, if it exists, and
+// records whether it hit.
+func (rc *RecordingCache) Get(key string) (value []byte, ok bool) {
+	value, ok = rc.cache.Get(key)
+	rc.record(trace.Get, key, len(value), ok)
+	return value, ok
+}
+
+// Remove removes and returns the value associated with the given key, if it
+// exists, and records whether it was found.
+func (rc *RecordingCache) Remove(key string) (value []byte, ok bool) {
+	value, ok = rc.cache.Remove(key)
+	rc.record(trace.Delete, key, len(value), ok)
+	return value, ok
+}
+
+// Set associates the given value with the given key and records whether the
+// binding was added.
+func (rc *RecordingCache) Set(key string, value []byte) bool {
+	ok := rc.cache.Set(key, value)
+	rc.record(trace.Set, key, len(value), ok)
+	return ok
+}
+
+// GetMulti gets each of keys from the wrapped cache, as a batch if it is a
+// Batcher, and records whether each hit.
+func (rc *RecordingCache) GetMulti(keys []string) (values [][]byte, found []bool) {
+	values, found = batchGet(rc.cache, keys)
+	for i, key := range keys {
+		rc.record(trace.Get, key, len(values[i]), found[i])
+	}
+	return values, found
+}
+
+// SetMulti sets each of keys to the value at the same index in the wrapped
+// cache, as a batch if it is a Batcher, and records whether each binding was
+// added.
+func (rc *RecordingCache) SetMulti(keys []string, values [][]byte) []bool {
+	ok := batchSet(rc.cache, keys, values)
+	for i := 0; i < len(keys) && i < len(values); i++ {
+		rc.record(trace.Set, keys[i], len(values[i]), ok[i])
+	}
+	return ok
+}
+
+// RemoveMulti removes each of keys from the wrapped cache, as a batch if it
+// is a Batcher, and records whether each was found.
+func (rc *RecordingCache) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	values, found = batchRemove(rc.cache, keys)
+	for i, key := range keys {
+		rc.record(trace.Delete, key, len(values[i]), found[i])
+	}
+	return values, found
+}
+
+// Len returns the number of bindings in the wrapped cache.
+func (rc *RecordingCache) Len() int {
+	return rc.cache.Len()
+}
+
+// Stats returns the statistics of the wrapped cache.
+func (rc *RecordingCache) Stats() *Stats {
+	return rc.cache.Stats()
+}
+
+// OnEvict registers f to be called with each binding the wrapped cache
+// evicts, if it is an Evicter. Evictions aren't recorded.
+func (rc *RecordingCache) OnEvict(f EvictFunc) {
+	onEvict(rc.cache, f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use, changing Stats or being recorded. ok is false if
+// the wrapped cache isn't an Inspector.
+func (rc *RecordingCache) Peek(key string) (value []byte, ok bool) {
+	return peek(rc.cache, key)
+}
+
+// Contains returns true if the given key is bound in the wrapped cache, and
+// false if it isn't an Inspector. It isn't recorded.
+func (rc *RecordingCache) Contains(key string) bool {
+	return contains(rc.cache, key)
+}
+
+// Range calls f with each binding in the wrapped cache's eviction order until
+// f returns false. f isn't called if the wrapped cache isn't an Inspector.
+func (rc *RecordingCache) Range(f func(key string, value []byte) bool) {
+	rangeBindings(rc.cache, f)
+}
+
+// Resize changes the capacity of the wrapped cache to limit bytes, if it is a
+// Resizer.
+func (rc *RecordingCache) Resize(limit int) {
+	resize(rc.cache, limit)
+}
+
+// Pin pins the binding for key in the wrapped cache so that it is never
+// evicted. Returns false if key isn't bound or the cache isn't a Pinner.
+func (rc *RecordingCache) Pin(key string) bool {
+	return pin(rc.cache, key)
+}
+
+// Unpin undoes one Pin of key in the wrapped cache. Returns false if key
+// isn't pinned or the cache isn't a Pinner.
+func (rc *RecordingCache) Unpin(key string) bool {
+	return unpin(rc.cache, key)
+}
+
+// isClosed returns true if the wrapped cache has been closed.
+func (rc *RecordingCache) isClosed() bool {
+	return isClosed(rc.cache)
+}
+
+// setError returns why the last Set on the wrapped cache failed.
+func (rc *RecordingCache) setError() error {
+	return setError(rc.cache)
+}
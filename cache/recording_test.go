@@ -0,0 +1,165 @@
+/******************************************************************************
+ * recording_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for recording.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"cos316.princeton.edu/assignment3/trace"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestRecordingCache(t *testing.T) {
+	var buf bytes.Buffer
+	clock := time.Unix(1000, 0)
+	now := func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	rc, err := NewRecordingCache(NewLru(100), &buf, RecordingOptions{Now: now})
+	if err != nil {
+		t.Errorf("Failed to create recording cache: %v", err)
+		t.FailNow()
+	}
+
+	rc.Get("key")
+	rc.Set("key", []byte("value"))
+	rc.Get("key")
+	rc.Remove("key")
+	if err := rc.Flush(); err != nil {
+		t.Errorf("Failed to flush recording: %v", err)
+		t.FailNow()
+	}
+
+	rr, err := trace.NewRecordReader(&buf)
+	if err != nil {
+		t.Errorf("Failed to read recording: %v", err)
+		t.FailNow()
+	}
+	hash := rc.HashKey("key")
+	want := []trace.Record{
+		{Op: trace.Get, Size: 0, Hit: false},
+		{Op: trace.Set, Size: 5, Hit: true},
+		{Op: trace.Get, Size: 5, Hit: true},
+		{Op: trace.Delete, Size: 5, Hit: true},
+	}
+	for i, w := range want {
+		rec, err := rr.NextRecord()
+		if err != nil {
+			t.Errorf("Failed to read record %d: %v", i, err)
+			t.FailNow()
+		}
+		w.KeyHash = hash
+		w.Time = time.Unix(1000, 0).Add(time.Duration(i+2) * time.Millisecond).UnixNano()
+		if rec != w {
+			t.Errorf("Record %d is %+v, expected %+v", i, rec, w)
+			t.FailNow()
+		}
+	}
+	if _, err := rr.NextRecord(); err != io.EOF {
+		t.Errorf("Expected end of recording, got %v", err)
+		t.FailNow()
+	}
+}
+
+// Inspecting, pinning and resizing pass through to the wrapped cache without
+// being recorded, so a RecordingCache can be used wherever the cache could.
+func TestRecordingPassThrough(t *testing.T) {
+	var buf bytes.Buffer
+	rc, _ := NewRecordingCache(NewLru(100), &buf, RecordingOptions{})
+	evicted := 0
+	rc.OnEvict(func(key string, value []byte) { evicted++ })
+	rc.Set("key", []byte("value"))
+	rc.Set("other", []byte("value"))
+
+	if value, ok := rc.Peek("key"); !ok || string(value) != "value" || !rc.Contains("key") ||
+		fmt.Sprint(Keys(rc)) != "[key other]" {
+		t.Errorf("Peek, Contains and Range should see the wrapped cache's bindings")
+		t.FailNow()
+	}
+	if !rc.Pin("key") {
+		t.Errorf("Pin should pin a bound key in the wrapped cache")
+		t.FailNow()
+	}
+	rc.Resize(10)
+	if !rc.Unpin("key") || evicted != 1 || rc.MaxStorage() != 10 || !rc.Contains("key") {
+		t.Errorf("Resize should evict the unpinned binding, evicted %d and can store %d", evicted, rc.MaxStorage())
+		t.FailNow()
+	}
+
+	// The two Sets are the only records
+	rc.Flush()
+	rr, _ := trace.NewRecordReader(&buf)
+	for i := 0; i < 2; i++ {
+		if rec, err := rr.NextRecord(); err != nil || rec.Op != trace.Set {
+			t.Errorf("Record %d is %+v (error %v), expected a Set", i, rec, err)
+			t.FailNow()
+		}
+	}
+	if _, err := rr.NextRecord(); err != io.EOF {
+		t.Errorf("Expected end of recording, got %v", err)
+		t.FailNow()
+	}
+}
+
+func TestRecordingSampling(t *testing.T) {
+	var buf bytes.Buffer
+	rc, _ := NewRecordingCache(NewLru(1000), &buf, RecordingOptions{SampleRate: 0.25})
+
+	sampled := map[string]bool{}
+	for i := 0; i < 400; i++ {
+		key := fmt.Sprintf("key%d", i%100)
+		rc.Get(key)
+		sampled[key] = rc.HashKey(key) <= rc.sampled
+	}
+	rc.Flush()
+
+	rr, _ := trace.NewRecordReader(&buf)
+	count := 0
+	for {
+		if _, err := rr.NextRecord(); err != nil {
+			break
+		}
+		count++
+	}
+
+	keys := 0
+	for _, s := range sampled {
+		if s {
+			keys++
+		}
+	}
+	if keys == 0 || keys == 100 || count != 4*keys {
+		t.Errorf("Recorded %d accesses to %d sampled keys", count, keys)
+		t.FailNow()
+	}
+}
+
+func TestRecordingSalt(t *testing.T) {
+	var buf bytes.Buffer
+	plain, _ := NewRecordingCache(NewLru(100), &buf, RecordingOptions{})
+	salted, _ := NewRecordingCache(NewLru(100), &buf, RecordingOptions{Salt: []byte("secret")})
+	other, _ := NewRecordingCache(NewLru(100), &buf, RecordingOptions{Salt: []byte("other")})
+
+	if plain.HashKey("key") == salted.HashKey("key") || salted.HashKey("key") == other.HashKey("key") {
+		t.Errorf("Salted hashes should differ from plain and differently salted hashes")
+		t.FailNow()
+	}
+	if salted.HashKey("key") != salted.HashKey("key") {
+		t.Errorf("Salted hashes should be stable")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,81 @@
+/******************************************************************************
+ * resize_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    A testing suite for Resize on every policy
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// Resizing up and down in the middle of a workload keeps every policy's
+// bookkeeping consistent.
+func TestResizeInvariants(t *testing.T) {
+	for _, policy := range experimentPolicies {
+		cache := policy.factory(128)
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 5000; i++ {
+			if i%100 == 0 {
+				limit := rng.Intn(256)
+				cache.(Resizer).Resize(limit)
+				checkInvariants(t, fmt.Sprintf("%s after Resize(%d)", policy.name, limit), cache)
+			}
+			key := fmt.Sprintf("%04d", rng.Intn(40))
+			if _, ok := cache.Get(key); !ok {
+				cache.Set(key, []byte(key))
+			}
+		}
+	}
+}
+
+// Shrinking an LRU evicts the least recently used bindings.
+func TestResizeLRU(t *testing.T) {
+	lru := NewLru(80)
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("%04d", i)
+		lru.Set(key, []byte(key))
+	}
+	lru.Get("0000")
+	lru.Get("0001")
+
+	lru.Resize(32)
+	keys := fmt.Sprint(Keys(lru))
+	if keys != "[0008 0009 0000 0001]" {
+		t.Errorf("After shrinking LRU holds %s, expected the 4 most recently used", keys)
+		t.FailNow()
+	}
+}
+
+// Resizing an LIRS keeps the same fraction of it for resident HIR entries.
+func TestResizeLIRS(t *testing.T) {
+	lirs := NewLIRS(1000, 0.1)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("%04d", i%120)
+		if _, ok := lirs.Get(key); !ok {
+			lirs.Set(key, []byte(key))
+		}
+	}
+
+	lirs.Resize(400)
+	if lirs.lirLimit != 360 || lirs.lirSize > lirs.lirLimit || lirs.currSize > 400 {
+		t.Errorf("After Resize(400) LIR set takes %d of %d bytes and the LIRS %d",
+			lirs.lirSize, lirs.lirLimit, lirs.currSize)
+		t.FailNow()
+	}
+
+	lirs.Resize(2000)
+	if lirs.lirLimit != 1800 {
+		t.Errorf("After Resize(2000) LIR limit is %d, expected 1800", lirs.lirLimit)
+		t.FailNow()
+	}
+}
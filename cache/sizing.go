@@ -0,0 +1,315 @@
+package cache
+
+import (
+	"sort"
+)
+
+// DefaultSizingFactors are the capacities, as multiples of MaxStorage, a
+// SizingCache estimates hits for when it isn't given any.
+var DefaultSizingFactors = []float64{0.5, 0.75, 1.25, 1.5, 2}
+
+// A SizingEstimate is how a cache would have done over the Gets a SizingCache
+// has seen if its capacity were Factor times what it is.
+type SizingEstimate struct {
+	Factor   float64
+	Capacity int // bytes
+	Hits     int
+	Misses   int
+}
+
+// HitRate returns the estimated fraction of Gets that would have been hits,
+// or 0 if there were none.
+func (estimate SizingEstimate) HitRate() float64 {
+	requests := estimate.Hits + estimate.Misses
+	if requests == 0 {
+		return 0
+	}
+	return float64(estimate.Hits) / float64(requests)
+}
+
+// sizingEntry is the hash of a key and the size of its binding.
+type sizingEntry struct {
+	hash uint64
+	size int
+}
+
+// A SizingCache wraps a cache and estimates how many of its Gets would have
+// hit at other capacities, so MaxStorage can be chosen from live traffic
+// instead of offline simulations. Only hashes and sizes of keys are kept.
+//
+// Misses on keys evicted recently enough to have fit in a larger cache count
+// as hits for it; evicted keys are remembered up to the largest factor's
+// extra capacity. Hits on keys that were used less recently than a smaller
+// cache could hold count as misses for it. Both estimates assume that
+// bindings are evicted in about the order they were last used, so they are
+// rougher for policies far from LRU. Distances are kept in a recency, so each
+// operation takes O(log n) time in the number of keys tracked.
+type SizingCache struct {
+	cache   Evicter
+	factors []float64 // ascending
+
+	resident *recency // by when they were last used
+	ghosts   *recency // by when they were evicted
+
+	requests int
+	hits     int
+	hitDelta []int // hits gained or lost at each factor
+}
+
+// NewSizingCache returns a SizingCache that wraps cache and estimates hits at
+// each capacity factor. It uses DefaultSizingFactors if factors is empty.
+// Factors that are not positive are ignored.
+func NewSizingCache(cache Evicter, factors ...float64) *SizingCache {
+	if len(factors) == 0 {
+		factors = DefaultSizingFactors
+	}
+
+	sc := new(SizingCache)
+	sc.cache = cache
+	for _, factor := range factors {
+		if factor > 0 {
+			sc.factors = append(sc.factors, factor)
+		}
+	}
+	sort.Float64s(sc.factors)
+	sc.hitDelta = make([]int, len(sc.factors))
+
+	sc.resident = newRecency()
+	sc.ghosts = newRecency()
+
+	cache.OnEvict(sc.evicted)
+	return sc
+}
+
+// Estimates returns the estimated hits and misses at each capacity factor,
+// in ascending order of capacity.
+func (sc *SizingCache) Estimates() []SizingEstimate {
+	estimates := make([]SizingEstimate, len(sc.factors))
+	for i, factor := range sc.factors {
+		hits := sc.hits + sc.hitDelta[i]
+		estimates[i] = SizingEstimate{
+			Factor:   factor,
+			Capacity: sc.capacity(factor),
+			Hits:     hits,
+			Misses:   sc.requests - hits,
+		}
+	}
+	return estimates
+}
+
+// capacity returns factor times the capacity of the wrapped cache.
+func (sc *SizingCache) capacity(factor float64) int {
+	return int(factor * float64(sc.cache.MaxStorage()))
+}
+
+// MaxStorage returns the maximum number of bytes the wrapped cache can store
+func (sc *SizingCache) MaxStorage() int {
+	return sc.cache.MaxStorage()
+}
+
+// RemainingStorage returns the number of unused bytes available in the wrapped cache
+func (sc *SizingCache) RemainingStorage() int {
+	return sc.cache.RemainingStorage()
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (sc *SizingCache) Get(key string) (value []byte, ok bool) {
+	value, ok = sc.cache.Get(key)
+	sc.got(key, value, ok)
+	return value, ok
+}
+
+// got counts a Get of key from the wrapped cache.
+func (sc *SizingCache) got(key string, value []byte, ok bool) {
+	sc.requests++
+	if ok {
+		sc.hits++
+		sc.hit(hashKey(key), len(key)+len(value))
+	} else {
+		sc.miss(hashKey(key))
+	}
+}
+
+// hit counts the hits smaller caches would have lost on a hit for hash, by
+// how many bytes were used since hash was, and makes hash the most recent.
+func (sc *SizingCache) hit(hash uint64, size int) {
+	// A binding added before the SizingCache wrapped the cache has no
+	// distance yet
+	if sc.resident.contains(hash) {
+		distance := sc.resident.since(hash)
+		for i, factor := range sc.factors {
+			if factor < 1 && distance > sc.capacity(factor) {
+				sc.hitDelta[i]--
+			}
+		}
+	}
+	sc.resident.add(hash, size)
+}
+
+// miss counts the hits larger caches would have gained on a miss for hash,
+// by how many bytes were evicted since hash was.
+func (sc *SizingCache) miss(hash uint64) {
+	if !sc.ghosts.contains(hash) {
+		return
+	}
+
+	distance := sc.ghosts.since(hash)
+	for i, factor := range sc.factors {
+		if factor > 1 && distance <= sc.capacity(factor)-sc.cache.MaxStorage() {
+			sc.hitDelta[i]++
+		}
+	}
+}
+
+// evicted moves an evicted key from the resident keys to the ghosts.
+func (sc *SizingCache) evicted(key string, value []byte) {
+	hash := hashKey(key)
+	sc.resident.remove(hash)
+	sc.ghosts.add(hash, len(key)+len(value))
+	sc.trimGhosts()
+}
+
+// trimGhosts forgets the oldest ghosts that no larger cache would still hold.
+func (sc *SizingCache) trimGhosts() {
+	limit := 0
+	if len(sc.factors) > 0 {
+		limit = sc.capacity(sc.factors[len(sc.factors)-1]) - sc.cache.MaxStorage()
+	}
+	for sc.ghosts.total > limit {
+		sc.ghosts.remove(sc.ghosts.last().hash)
+	}
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (sc *SizingCache) Remove(key string) (value []byte, ok bool) {
+	value, ok = sc.cache.Remove(key)
+	if ok {
+		sc.resident.remove(hashKey(key))
+	}
+	return value, ok
+}
+
+// GetMulti gets each of keys from the wrapped cache, as a batch if it is a
+// Batcher, and counts each Get as Get does.
+func (sc *SizingCache) GetMulti(keys []string) (values [][]byte, found []bool) {
+	values, found = batchGet(sc.cache, keys)
+	for i, key := range keys {
+		sc.got(key, values[i], found[i])
+	}
+	return values, found
+}
+
+// SetMulti sets each of keys to the value at the same index in the wrapped
+// cache, as a batch if it is a Batcher, and returns whether each binding was
+// added.
+func (sc *SizingCache) SetMulti(keys []string, values [][]byte) []bool {
+	for _, key := range keys {
+		sc.ghosts.remove(hashKey(key))
+	}
+	ok := batchSet(sc.cache, keys, values)
+	for i := range ok {
+		if ok[i] {
+			sc.added(keys[i], values[i])
+		}
+	}
+	return ok
+}
+
+// RemoveMulti removes each of keys from the wrapped cache, as a batch if it
+// is a Batcher, returning the value of each and whether it was found.
+func (sc *SizingCache) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	values, found = batchRemove(sc.cache, keys)
+	for i, key := range keys {
+		if found[i] {
+			sc.resident.remove(hashKey(key))
+		}
+	}
+	return values, found
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+func (sc *SizingCache) Set(key string, value []byte) bool {
+	// Forget the key as a ghost first, so that it doesn't count against the
+	// ghosts' capacity while the wrapped cache evicts to make room for it
+	hash := hashKey(key)
+	sc.ghosts.remove(hash)
+	if !sc.cache.Set(key, value) {
+		return false
+	}
+	sc.added(key, value)
+	return true
+}
+
+// added makes a binding set in the wrapped cache the most recent resident.
+func (sc *SizingCache) added(key string, value []byte) {
+	sc.resident.add(hashKey(key), len(key)+len(value))
+}
+
+// Len returns the number of bindings in the wrapped cache.
+func (sc *SizingCache) Len() int {
+	return sc.cache.Len()
+}
+
+// Stats returns statistics about how many search hits and misses have
+// occurred in the wrapped cache.
+func (sc *SizingCache) Stats() *Stats {
+	return sc.cache.Stats()
+}
+
+// OnEvict registers f to be called with each binding the wrapped cache evicts.
+func (sc *SizingCache) OnEvict(f EvictFunc) {
+	sc.cache.OnEvict(f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats. ok is false if the wrapped
+// cache isn't an Inspector.
+func (sc *SizingCache) Peek(key string) (value []byte, ok bool) {
+	return peek(sc.cache, key)
+}
+
+// Contains returns true if the given key is bound in the wrapped cache, and
+// false if it isn't an Inspector.
+func (sc *SizingCache) Contains(key string) bool {
+	return contains(sc.cache, key)
+}
+
+// Range calls f with each binding in the wrapped cache's eviction order until
+// f returns false. f isn't called if the wrapped cache isn't an Inspector.
+func (sc *SizingCache) Range(f func(key string, value []byte) bool) {
+	rangeBindings(sc.cache, f)
+}
+
+// Resize changes the capacity of the wrapped cache to limit bytes, if it is a
+// Resizer. Estimates are then for multiples of the new capacity, but
+// hits counted before the resize were counted against the old one.
+func (sc *SizingCache) Resize(limit int) {
+	resize(sc.cache, limit)
+	sc.trimGhosts()
+}
+
+// Pin pins the binding for key in the wrapped cache so that it is never
+// evicted. Returns false if key isn't bound or the cache isn't a Pinner.
+func (sc *SizingCache) Pin(key string) bool {
+	return pin(sc.cache, key)
+}
+
+// Unpin undoes one Pin of key in the wrapped cache. Returns false if key
+// isn't pinned or the cache isn't a Pinner.
+func (sc *SizingCache) Unpin(key string) bool {
+	return unpin(sc.cache, key)
+}
+
+// isClosed returns true if the wrapped cache has been closed.
+func (sc *SizingCache) isClosed() bool {
+	return isClosed(sc.cache)
+}
+
+// setError returns why the last Set on the wrapped cache failed.
+func (sc *SizingCache) setError() error {
+	return setError(sc.cache)
+}
@@ -0,0 +1,115 @@
+/******************************************************************************
+ * sizing_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for sizing.go and the OnEvict hook
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// Every policy reports each binding it evicts, and only those.
+func TestOnEvict(t *testing.T) {
+	for _, policy := range experimentPolicies {
+		cache := policy.factory(100).(Evicter)
+		evicted := map[string]bool{}
+		cache.OnEvict(func(key string, value []byte) {
+			if key != string(value) {
+				t.Errorf("%s evicted key %s with value %s", policy.name, key, value)
+			}
+			evicted[key] = true
+		})
+
+		cache.Set("removed", []byte("removed"))
+		cache.Remove("removed")
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("key%02d", i)
+			cache.Set(key, []byte(key))
+		}
+
+		if len(evicted)+cache.Len() != 50 || evicted["removed"] {
+			t.Errorf("%s reported %d evictions but holds %d of 50 bindings", policy.name, len(evicted), cache.Len())
+			t.FailNow()
+		}
+		for key := range evicted {
+			if _, found := cache.Get(key); found {
+				t.Errorf("%s reported evicting %s but still holds it", policy.name, key)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// An LRU's hits at other capacities are estimated exactly when all bindings
+// have the same size.
+func TestSizingLRU(t *testing.T) {
+	capacity := 200
+	factors := []float64{0.5, 0.75, 1, 1.5, 2}
+	sc := NewSizingCache(NewLru(capacity), factors...)
+	caches := []Cache{}
+	for _, factor := range factors {
+		caches = append(caches, NewLru(int(factor*float64(capacity))))
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("%05d", rng.Intn(60))
+		for _, cache := range append(caches, sc) {
+			if _, found := cache.Get(key); !found {
+				cache.Set(key, []byte(key))
+			}
+		}
+	}
+
+	for i, estimate := range sc.Estimates() {
+		stats := caches[i].Stats()
+		if estimate.Hits != stats.Hits || estimate.Misses != stats.Misses {
+			t.Errorf("Estimated %d hits and %d misses at %.2fx, but an LRU had %d and %d",
+				estimate.Hits, estimate.Misses, estimate.Factor, stats.Hits, stats.Misses)
+			t.FailNow()
+		}
+		if estimate.Capacity != caches[i].MaxStorage() {
+			t.Errorf("Estimate at %.2fx should have capacity %d, but has %d",
+				estimate.Factor, caches[i].MaxStorage(), estimate.Capacity)
+			t.FailNow()
+		}
+	}
+}
+
+// Evicted keys are remembered only up to the largest extra capacity.
+func TestSizingGhostLimit(t *testing.T) {
+	capacity := 100
+	sc := NewSizingCache(NewFIFO(capacity), 1.5)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("%05d", i)
+		sc.Set(key, []byte(key))
+	}
+	if sc.ghosts.total > capacity/2 || sc.ghosts.len() != 5 {
+		t.Errorf("SizingCache should remember 5 evicted keys, but remembers %d", sc.ghosts.len())
+		t.FailNow()
+	}
+
+	// a miss on a remembered key is a hit for the larger cache
+	sc.Get("00089")
+	sc.Get("00010")
+	estimate := sc.Estimates()[0]
+	if estimate.Hits != 1 || estimate.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss at 1.5x, but got %d and %d", estimate.Hits, estimate.Misses)
+		t.FailNow()
+	}
+	if sc.Stats().Misses != 2 {
+		t.Errorf("SizingCache should not change the wrapped cache's stats")
+		t.FailNow()
+	}
+}
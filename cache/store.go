@@ -0,0 +1,457 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Store is the slower storage behind a StoredCache, such as a file or a
+// database, that holds every binding whether or not it is cached.
+type Store interface {
+	// Load returns the value stored for key. ok is false if there is none.
+	Load(key string) (value []byte, ok bool, err error)
+
+	// Store stores value for key, replacing any value stored for it.
+	Store(key string, value []byte) error
+
+	// Delete deletes the value stored for key, if there is one.
+	Delete(key string) error
+}
+
+// A WriteMode is when a StoredCache writes bindings to its Store.
+type WriteMode int
+
+const (
+	// WriteThrough writes each binding to the Store when it is Set, before
+	// caching it.
+	WriteThrough WriteMode = iota
+
+	// WriteBack only caches a binding when it is Set, marking it dirty. Dirty
+	// bindings are written to the Store when they are evicted, every flush
+	// interval, and on Flush and Close.
+	WriteBack
+)
+
+// A FrontCache is a cache that can be put in front of a Store by a
+// StoredCache. Every policy in this package is a FrontCache.
+type FrontCache interface {
+	Evicter
+	Inspector
+	Resizer
+}
+
+// A StoredCache is a cache in front of a Store. Gets that miss the cache
+// load the binding from the Store and cache it, and Removes delete it from
+// both. Sets are written to the Store according to the WriteMode. After
+// Close, Gets, Sets and Removes fail without using the cache or the Store,
+// and SetE, GetE and RemoveE return ErrClosed.
+//
+// Operations are serialized with a mutex, since in WriteBack mode dirty
+// bindings are flushed from another goroutine. The wrapped cache must only
+// be used through the StoredCache.
+type StoredCache struct {
+	mu    sync.Mutex
+	cache FrontCache
+	store Store
+	mode  WriteMode
+	dirty map[string]bool
+
+	// Bindings that are no longer cached and failed to be written, which
+	// are written again on the next flush
+	pending map[string][]byte
+
+	err    error // first error writing to the Store outside of Flush
+	setErr error // why the last Set failed
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewStoredCache returns a StoredCache that caches the bindings of store in
+// cache. In WriteBack mode with a positive flushEvery, dirty bindings are
+// flushed that often until Close.
+func NewStoredCache(cache FrontCache, store Store, mode WriteMode, flushEvery time.Duration) *StoredCache {
+	sc := new(StoredCache)
+	sc.cache = cache
+	sc.store = store
+	sc.mode = mode
+	sc.dirty = map[string]bool{}
+	sc.pending = map[string][]byte{}
+	cache.OnEvict(func(key string, value []byte) {
+		// Dirty bindings are written before their only copy is dropped
+		if sc.dirty[key] {
+			delete(sc.dirty, key)
+			sc.write(key, value)
+		}
+	})
+
+	if mode == WriteBack && flushEvery > 0 {
+		sc.stop = make(chan struct{})
+		sc.done = make(chan struct{})
+		go sc.flushEvery(flushEvery)
+	}
+	return sc
+}
+
+// flushEvery flushes dirty bindings every interval until stop is closed.
+func (sc *StoredCache) flushEvery(interval time.Duration) {
+	defer close(sc.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sc.mu.Lock()
+			if err := sc.flush(); err != nil && sc.err == nil {
+				sc.err = err
+			}
+			sc.mu.Unlock()
+		case <-sc.stop:
+			return
+		}
+	}
+}
+
+// write writes a binding that isn't cached to the Store. If that fails the
+// binding is kept pending, so it can still be read and is written again on
+// the next flush, and the error is remembered and returned.
+func (sc *StoredCache) write(key string, value []byte) error {
+	err := sc.store.Store(key, value)
+	if err != nil {
+		sc.pending[key] = value
+		if sc.err == nil {
+			sc.err = err
+		}
+	}
+	return err
+}
+
+// flush writes every dirty and pending binding to the Store, in order of
+// key, and returns the first error. Bindings that fail to be written stay
+// dirty or pending.
+func (sc *StoredCache) flush() error {
+	keys := make([]string, 0, len(sc.dirty)+len(sc.pending))
+	for key := range sc.dirty {
+		keys = append(keys, key)
+	}
+	for key := range sc.pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var first error
+	for _, key := range keys {
+		value, pending := sc.pending[key]
+		if !pending {
+			value, _ = sc.cache.Peek(key)
+		}
+		if err := sc.store.Store(key, value); err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		delete(sc.dirty, key)
+		delete(sc.pending, key)
+	}
+	return first
+}
+
+// MaxStorage returns the maximum number of bytes the wrapped cache can store
+func (sc *StoredCache) MaxStorage() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.MaxStorage()
+}
+
+// RemainingStorage returns the number of unused bytes available in the wrapped cache
+func (sc *StoredCache) RemainingStorage() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.RemainingStorage()
+}
+
+// Get returns the value associated with the given key, if it exists, loading
+// it from the Store and caching it if the cache misses. A binding that was
+// evicted but failed to be written is cached again, still dirty. ok is false
+// if neither has it, loading it failed or the StoredCache is closed.
+func (sc *StoredCache) Get(key string) (value []byte, ok bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.get(key)
+}
+
+// get is Get for a caller holding sc.mu.
+func (sc *StoredCache) get(key string) (value []byte, ok bool) {
+	if sc.closed {
+		return nil, false
+	}
+
+	if value, ok = sc.cache.Get(key); ok {
+		return value, true
+	}
+	if value, ok = sc.pending[key]; ok {
+		if sc.cache.Set(key, value) {
+			delete(sc.pending, key)
+			sc.dirty[key] = true
+		}
+		return value, true
+	}
+	value, ok, err := sc.store.Load(key)
+	if err != nil {
+		if sc.err == nil {
+			sc.err = err
+		}
+		return nil, false
+	}
+	if ok {
+		sc.cacheStored(key, value)
+	}
+	return value, ok
+}
+
+// Remove removes and returns the value associated with the given key from
+// the cache, if it exists, and deletes it from the Store. Does nothing once
+// the StoredCache is closed.
+func (sc *StoredCache) Remove(key string) (value []byte, ok bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.remove(key)
+}
+
+// remove is Remove for a caller holding sc.mu.
+func (sc *StoredCache) remove(key string) (value []byte, ok bool) {
+	if sc.closed {
+		return nil, false
+	}
+
+	delete(sc.dirty, key)
+	delete(sc.pending, key)
+	value, ok = sc.cache.Remove(key)
+	if err := sc.store.Delete(key); err != nil && sc.err == nil {
+		sc.err = err
+	}
+	return value, ok
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room, and writes it to the Store according to the WriteMode.
+// Returns false if the StoredCache is closed, or if the binding couldn't be
+// cached, in which case it is still written, or, in WriteThrough mode, if
+// writing it failed. SetE returns ErrClosed, the Store's error or
+// ErrNotCached for these.
+func (sc *StoredCache) Set(key string, value []byte) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.setErr = sc.set(key, value)
+	return sc.setErr == nil
+}
+
+// set is Set for a caller holding sc.mu, returning why it failed.
+func (sc *StoredCache) set(key string, value []byte) error {
+	if sc.closed {
+		return ErrClosed
+	}
+	// The new value replaces one that failed to be written
+	delete(sc.pending, key)
+	if sc.mode == WriteThrough {
+		if err := sc.store.Store(key, value); err != nil {
+			if sc.err == nil {
+				sc.err = err
+			}
+			return err
+		}
+		if !sc.cacheStored(key, value) {
+			return ErrNotCached
+		}
+		return nil
+	}
+
+	if !sc.cache.Set(key, value) {
+		// Never cached, so it is written right away rather than lost
+		err := sc.write(key, value)
+		sc.uncache(key)
+		if err != nil {
+			return err
+		}
+		return ErrNotCached
+	}
+	sc.dirty[key] = true
+	return nil
+}
+
+// GetMulti gets each of keys as Get does while holding the lock once,
+// returning the value of each and whether it was found.
+func (sc *StoredCache) GetMulti(keys []string) (values [][]byte, found []bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	values = make([][]byte, len(keys))
+	found = make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = sc.get(key)
+	}
+	return values, found
+}
+
+// SetMulti sets each of keys to the value at the same index as Set does while
+// holding the lock once: in WriteBack mode each binding is marked dirty, and
+// in WriteThrough mode each is written to the Store before it is cached.
+// Returns whether each binding was added.
+func (sc *StoredCache) SetMulti(keys []string, values [][]byte) []bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	ok := make([]bool, len(keys))
+	for i := 0; i < len(keys) && i < len(values); i++ {
+		err := sc.set(keys[i], values[i])
+		if err != nil {
+			sc.setErr = err
+		}
+		ok[i] = err == nil
+	}
+	return ok
+}
+
+// RemoveMulti removes each of keys as Remove does while holding the lock
+// once, returning the value of each and whether it was found.
+func (sc *StoredCache) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	values = make([][]byte, len(keys))
+	found = make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = sc.remove(key)
+	}
+	return values, found
+}
+
+// setError returns why the last Set failed.
+func (sc *StoredCache) setError() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.setErr
+}
+
+// cacheStored caches a binding that is already stored. If it doesn't fit, any
+// older value cached for key is removed, since the Store has moved on.
+func (sc *StoredCache) cacheStored(key string, value []byte) bool {
+	delete(sc.dirty, key)
+	if !sc.cache.Set(key, value) {
+		sc.uncache(key)
+		return false
+	}
+	return true
+}
+
+// uncache removes key from the cache without writing it to the Store.
+func (sc *StoredCache) uncache(key string) {
+	delete(sc.dirty, key)
+	sc.cache.Remove(key)
+}
+
+// Len returns the number of bindings in the wrapped cache.
+func (sc *StoredCache) Len() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.Len()
+}
+
+// Stats returns statistics about how many search hits and misses have
+// occurred in the wrapped cache.
+func (sc *StoredCache) Stats() *Stats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.Stats()
+}
+
+// OnEvict registers f to be called with each binding the wrapped cache
+// evicts, after it has been written to the Store if it was dirty.
+func (sc *StoredCache) OnEvict(f EvictFunc) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.cache.OnEvict(f)
+}
+
+// Dirty returns the number of bindings set in WriteBack mode that haven't
+// been written to the Store yet, including evicted ones that failed to be.
+func (sc *StoredCache) Dirty() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return len(sc.dirty) + len(sc.pending)
+}
+
+// Flush writes every dirty binding to the Store, including evicted ones that
+// failed to be written before. It returns the first error
+// writing to the Store since the last Flush, including writes of evicted
+// bindings and timed flushes.
+func (sc *StoredCache) Flush() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	err := sc.flush()
+	if sc.err != nil {
+		err = sc.err
+		sc.err = nil
+	}
+	return err
+}
+
+// Close stops timed flushes and flushes every dirty binding, returning the
+// same errors as Flush, or ErrClosed if it was already closed.
+func (sc *StoredCache) Close() error {
+	sc.mu.Lock()
+	if sc.closed {
+		sc.mu.Unlock()
+		return ErrClosed
+	}
+	sc.closed = true
+	sc.mu.Unlock()
+
+	// The flushing goroutine needs the lock to stop
+	if sc.stop != nil {
+		close(sc.stop)
+		<-sc.done
+	}
+	return sc.Flush()
+}
+
+// isClosed returns true once Close has been called.
+func (sc *StoredCache) isClosed() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.closed
+}
+
+// Peek returns the value cached for the given key, if it exists, without
+// counting as a use, changing Stats or loading it from the Store.
+func (sc *StoredCache) Peek(key string) (value []byte, ok bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.Peek(key)
+}
+
+// Contains returns true if the given key is cached.
+func (sc *StoredCache) Contains(key string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.Contains(key)
+}
+
+// Range calls f with each cached binding in the wrapped cache's eviction
+// order until f returns false. f must not use the StoredCache.
+func (sc *StoredCache) Range(f func(key string, value []byte) bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.cache.Range(f)
+}
+
+// Resize changes the capacity of the wrapped cache to limit bytes, writing
+// dirty bindings it evicts to the Store.
+func (sc *StoredCache) Resize(limit int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.cache.Resize(limit)
+}
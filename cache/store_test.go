@@ -0,0 +1,269 @@
+/******************************************************************************
+ * store_test.go
+ * Author:
+ * Usage:    `go test -race`  or  `go test -race -v`
+ * Description:
+ *    A testing suite for store.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// In WriteThrough mode Sets reach the Store right away, and Gets that miss
+// the cache load from it.
+func TestWriteThrough(t *testing.T) {
+	store := newMapStore()
+	sc := NewStoredCache(NewLru(30), store, WriteThrough, 0)
+	for i := 0; i < 5; i++ {
+		sc.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+	if len(store.values) != 5 || sc.Dirty() != 0 {
+		t.Errorf("Store holds %d bindings with %d dirty, expected 5 and none", len(store.values), sc.Dirty())
+		t.FailNow()
+	}
+
+	// key0 was evicted, so it is loaded and cached again
+	if sc.Contains("key0") {
+		t.Errorf("Expected key0 to have been evicted")
+		t.FailNow()
+	}
+	if value, ok := sc.Get("key0"); !ok || string(value) != "value" || !sc.Contains("key0") {
+		t.Errorf("Get(key0) returned %q (found: %t) and didn't cache it", value, ok)
+		t.FailNow()
+	}
+
+	sc.Remove("key0")
+	if _, ok := store.values["key0"]; ok {
+		t.Errorf("Remove should delete the binding from the Store")
+		t.FailNow()
+	}
+}
+
+// In WriteBack mode Sets stay dirty until their bindings are evicted or
+// flushed, for every policy.
+func TestWriteBack(t *testing.T) {
+	for _, policy := range experimentPolicies {
+		store := newMapStore()
+		sc := NewStoredCache(policy.factory(100).(FrontCache), store, WriteBack, 0)
+		for i := 0; i < 200; i++ {
+			sc.Set(fmt.Sprintf("key%d", i%40), []byte(fmt.Sprintf("%d", i)))
+		}
+		evicted := 40 - sc.Len()
+		if len(store.values) < evicted || sc.Dirty() != sc.Len() {
+			t.Errorf("%s: Store holds %d bindings with %d dirty after %d were evicted",
+				policy.name, len(store.values), sc.Dirty(), evicted)
+			t.FailNow()
+		}
+
+		// Evicting directly from the wrapped cache also writes dirty bindings
+		evictOne(sc.cache)
+		if sc.Dirty() != sc.Len() {
+			t.Errorf("%s: %d bindings dirty after an eviction, but %d cached", policy.name, sc.Dirty(), sc.Len())
+			t.FailNow()
+		}
+
+		if err := sc.Close(); err != nil {
+			t.Errorf("%s: Close returned %v", policy.name, err)
+			t.FailNow()
+		}
+		for i := 160; i < 200; i++ {
+			key := fmt.Sprintf("key%d", i%40)
+			if value := string(store.values[key]); value != fmt.Sprintf("%d", i) {
+				t.Errorf("%s: Store holds %q for %s, expected the last value set, %d", policy.name, value, key, i)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+// Dirty bindings are flushed on a timer until Close.
+func TestWriteBackTimer(t *testing.T) {
+	store := newMapStore()
+	sc := NewStoredCache(NewLru(100), store, WriteBack, time.Millisecond)
+	defer sc.Close()
+	sc.Set("key", []byte("value"))
+
+	for i := 0; sc.Dirty() != 0; i++ {
+		if i == 1000 {
+			t.Errorf("Dirty binding wasn't flushed within a second")
+			t.FailNow()
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if value, _, _ := store.Load("key"); string(value) != "value" {
+		t.Errorf("Store holds %q for the flushed binding", value)
+		t.FailNow()
+	}
+}
+
+// Bindings that fail to be written stay dirty, and Flush reports the error.
+func TestStoreErrors(t *testing.T) {
+	store := newMapStore()
+	sc := NewStoredCache(NewLru(100), store, WriteBack, 0)
+	sc.Set("key", []byte("value"))
+
+	store.fail = true
+	if err := sc.Flush(); err != errStoreFailed || sc.Dirty() != 1 {
+		t.Errorf("Flush to a failing Store returned %v and left %d dirty", err, sc.Dirty())
+		t.FailNow()
+	}
+	store.fail = false
+	if err := sc.Flush(); err != nil || sc.Dirty() != 0 {
+		t.Errorf("Flush returned %v and left %d dirty once the Store recovered", err, sc.Dirty())
+		t.FailNow()
+	}
+
+	// SetE tells a binding that was stored but not cached from one that
+	// wasn't stored
+	if err := SetE(NewSyncCache(sc), "large", make([]byte, 100)); err != ErrNotCached || store.values["large"] == nil {
+		t.Errorf("SetE of a binding too large to cache returned %v", err)
+		t.FailNow()
+	}
+	store.fail = true
+	through := NewStoredCache(NewLru(100), store, WriteThrough, 0)
+	if through.Set("key", []byte("value")) {
+		t.Errorf("Set in WriteThrough mode should fail when the Store does")
+		t.FailNow()
+	}
+	if err := SetE(NewSyncCache(through), "key", []byte("value")); err != errStoreFailed {
+		t.Errorf("SetE in WriteThrough mode to a failing Store returned %v", err)
+		t.FailNow()
+	}
+}
+
+// Evicted dirty bindings that fail to be written aren't lost: Gets still
+// find them, and Flush writes them once the Store recovers.
+func TestStoreEvictErrors(t *testing.T) {
+	store := newMapStore()
+	sc := NewStoredCache(NewLru(30), store, WriteBack, 0)
+	sc.Set("key0", []byte("value0"))
+	store.fail = true
+	sc.Set("key1", []byte("value1"))
+	sc.Set("key2", []byte("value2"))
+	sc.Set("key3", []byte("value3"))
+	if sc.Contains("key0") || sc.Dirty() != 4 {
+		t.Errorf("Expected key0 to be evicted and still dirty with the 3 cached, found %d dirty", sc.Dirty())
+		t.FailNow()
+	}
+
+	if value, ok := sc.Get("key0"); !ok || string(value) != "value0" {
+		t.Errorf("Get of an evicted binding that failed to be written returned %q (found: %t)", value, ok)
+		t.FailNow()
+	}
+	if err := sc.Flush(); err != errStoreFailed || sc.Dirty() != 4 {
+		t.Errorf("Flush to a failing Store returned %v and left %d dirty", err, sc.Dirty())
+		t.FailNow()
+	}
+
+	store.fail = false
+	if err := sc.Flush(); err != nil || sc.Dirty() != 0 {
+		t.Errorf("Flush returned %v and left %d dirty once the Store recovered", err, sc.Dirty())
+		t.FailNow()
+	}
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if value := string(store.values[key]); value != fmt.Sprintf("value%d", i) {
+			t.Errorf("Store holds %q for %s", value, key)
+			t.FailNow()
+		}
+	}
+}
+
+// After Close, operations fail without reaching the Store and the typed
+// helpers return ErrClosed.
+func TestStoreClosed(t *testing.T) {
+	store := newMapStore()
+	sc := NewStoredCache(NewLru(100), store, WriteBack, 0)
+	sc.Set("key", []byte("value"))
+	if err := sc.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+		t.FailNow()
+	}
+
+	if sc.Set("other", []byte("value")) || len(store.values) != 1 {
+		t.Errorf("Set after Close should fail without writing, Store holds %d bindings", len(store.values))
+		t.FailNow()
+	}
+	if _, ok := sc.Remove("key"); ok || len(store.values) != 1 {
+		t.Errorf("Remove after Close should fail without deleting, Store holds %d bindings", len(store.values))
+		t.FailNow()
+	}
+	if err := SetE(sc, "other", []byte("value")); err != ErrClosed {
+		t.Errorf("SetE after Close returned %v", err)
+		t.FailNow()
+	}
+	if _, err := GetE(sc, "key"); err != ErrClosed {
+		t.Errorf("GetE after Close returned %v", err)
+		t.FailNow()
+	}
+	if _, err := RemoveE(sc, "key"); err != ErrClosed {
+		t.Errorf("RemoveE after Close returned %v", err)
+		t.FailNow()
+	}
+	if err := SetE(NewSyncCache(sc), "other", []byte("value")); err != ErrClosed {
+		t.Errorf("SetE through a SyncCache after Close returned %v", err)
+		t.FailNow()
+	}
+	if err := sc.Close(); err != ErrClosed {
+		t.Errorf("Closing twice returned %v", err)
+		t.FailNow()
+	}
+}
+
+/******************************************************************************/
+/*                                 Helpers                                    */
+/******************************************************************************/
+
+var errStoreFailed = errors.New("store failed")
+
+// A mapStore is a Store in memory that can be made to fail.
+type mapStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	fail   bool
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{values: map[string][]byte{}}
+}
+
+func (ms *mapStore) Load(key string) ([]byte, bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.fail {
+		return nil, false, errStoreFailed
+	}
+	value, ok := ms.values[key]
+	return value, ok, nil
+}
+
+func (ms *mapStore) Store(key string, value []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.fail {
+		return errStoreFailed
+	}
+	ms.values[key] = value
+	return nil
+}
+
+func (ms *mapStore) Delete(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.fail {
+		return errStoreFailed
+	}
+	delete(ms.values, key)
+	return nil
+}
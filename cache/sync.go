@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"sync"
+)
+
+// A SyncCache wraps a Cache so that it can be used from several goroutines at
+// once. Every policy changes its state on Get, so operations are serialized
+// with a single mutex.
+type SyncCache struct {
+	mu    sync.Mutex
+	cache Cache
+}
+
+// NewSyncCache returns a SyncCache that passes operations through to cache.
+// cache must not be used other than through the SyncCache.
+func NewSyncCache(cache Cache) *SyncCache {
+	return &SyncCache{cache: cache}
+}
+
+// MaxStorage returns the maximum number of bytes the wrapped cache can store
+func (sc *SyncCache) MaxStorage() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.MaxStorage()
+}
+
+// RemainingStorage returns the number of unused bytes available in the wrapped cache
+func (sc *SyncCache) RemainingStorage() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.RemainingStorage()
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (sc *SyncCache) Get(key string) (value []byte, ok bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.Get(key)
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (sc *SyncCache) Remove(key string) (value []byte, ok bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.Remove(key)
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+func (sc *SyncCache) Set(key string, value []byte) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.Set(key, value)
+}
+
+// Len returns the number of bindings in the wrapped cache.
+func (sc *SyncCache) Len() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.cache.Len()
+}
+
+// Stats returns a copy of the wrapped cache's statistics, since the original
+// keeps changing while other goroutines use the cache. Windows must be set up
+// with TrackWindows or TrackIntervals on the wrapped cache's Stats before it is
+// wrapped.
+func (sc *SyncCache) Stats() *Stats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	stats := *sc.cache.Stats()
+	if stats.Weights != nil {
+		stats.Weights = map[string]float64{}
+		for name, weight := range sc.cache.Stats().Weights {
+			stats.Weights[name] = weight
+		}
+	}
+	if stats.window != nil {
+		stats.window = stats.window.clone()
+	}
+	return &stats
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats. ok is false if the wrapped
+// cache isn't an Inspector.
+func (sc *SyncCache) Peek(key string) (value []byte, ok bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return peek(sc.cache, key)
+}
+
+// Contains returns true if the given key is bound in the wrapped cache, and
+// false if it isn't an Inspector.
+func (sc *SyncCache) Contains(key string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return contains(sc.cache, key)
+}
+
+// Range calls f with each binding in the wrapped cache's eviction order until
+// f returns false. Other goroutines wait until Range returns, and f must not
+// use the SyncCache. f isn't called if the wrapped cache isn't an Inspector.
+func (sc *SyncCache) Range(f func(key string, value []byte) bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	rangeBindings(sc.cache, f)
+}
+
+// Resize changes the capacity of the wrapped cache to limit bytes, if it is a
+// Resizer.
+func (sc *SyncCache) Resize(limit int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	resize(sc.cache, limit)
+}
+
+// Pin pins the binding for key in the wrapped cache so that it is never
+// evicted. Returns false if key isn't bound or the cache isn't a Pinner.
+func (sc *SyncCache) Pin(key string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return pin(sc.cache, key)
+}
+
+// Unpin undoes one Pin of key in the wrapped cache. Returns false if key
+// isn't pinned or the cache isn't a Pinner.
+func (sc *SyncCache) Unpin(key string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return unpin(sc.cache, key)
+}
+
+// GetMulti gets each of keys from the wrapped cache while holding the lock
+// once, returning the value of each and whether it was found.
+func (sc *SyncCache) GetMulti(keys []string) (values [][]byte, found []bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return batchGet(sc.cache, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index in the wrapped
+// cache while holding the lock once, and returns whether each binding was
+// added. If the wrapped cache is a Batcher it evicts once for the whole batch.
+func (sc *SyncCache) SetMulti(keys []string, values [][]byte) []bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return batchSet(sc.cache, keys, values)
+}
+
+// RemoveMulti removes each of keys from the wrapped cache while holding the
+// lock once, returning the value of each and whether it was found.
+func (sc *SyncCache) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return batchRemove(sc.cache, keys)
+}
+
+// isClosed returns true if the wrapped cache has been closed.
+func (sc *SyncCache) isClosed() bool {
+	return isClosed(sc.cache)
+}
+
+// setError returns why the last Set on the wrapped cache failed.
+func (sc *SyncCache) setError() error {
+	return setError(sc.cache)
+}
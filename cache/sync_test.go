@@ -0,0 +1,69 @@
+/******************************************************************************
+ * sync_test.go
+ * Author:
+ * Usage:    `go test -race`  or  `go test -race -v`
+ * Description:
+ *    An unit testing suite for sync.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestSyncCacheConcurrent(t *testing.T) {
+	for _, policy := range experimentPolicies {
+		cache := policy.factory(256)
+		cache.Stats().TrackWindows(4, 100)
+		sc := NewSyncCache(cache)
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					key := fmt.Sprintf("%d", (g*7+i)%40)
+					if _, ok := sc.Get(key); !ok {
+						sc.Set(key, []byte(key))
+					}
+					if i%50 == 0 {
+						sc.Remove(key)
+						sc.Stats().RecentHitRate()
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+
+		stats := sc.Stats()
+		if stats.Hits+stats.Misses != 8*500 {
+			t.Errorf("%s counted %d hits and %d misses, expected %d lookups", policy.name, stats.Hits, stats.Misses, 8*500)
+			t.FailNow()
+		}
+		if sc.RemainingStorage() < 0 || sc.Len() == 0 {
+			t.Errorf("%s has %d bindings and %d bytes remaining", policy.name, sc.Len(), sc.RemainingStorage())
+			t.FailNow()
+		}
+	}
+}
+
+// Stats returns a snapshot that later operations don't change.
+func TestSyncCacheStats(t *testing.T) {
+	sc := NewSyncCache(NewLru(64))
+	sc.Get("a")
+	stats := sc.Stats()
+	sc.Get("a")
+
+	if stats.Misses != 1 || sc.Stats().Misses != 2 {
+		t.Errorf("Stats should be a snapshot, but have %d misses", stats.Misses)
+		t.FailNow()
+	}
+}
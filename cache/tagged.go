@@ -0,0 +1,215 @@
+package cache
+
+// A TaggedCache wraps a cache so that groups of bindings can be removed at
+// once: those Set with a tag, with InvalidateTag, or those whose keys start
+// with a prefix, with RemovePrefix. Keys are indexed in a radix tree, so
+// neither looks at bindings outside the group. The wrapped cache must only be
+// used through the TaggedCache.
+type TaggedCache struct {
+	cache   Evicter
+	keys    *radixTree
+	tags    map[string]map[string]bool // keys carrying each tag
+	keyTags map[string][]string        // tags carried by each key
+}
+
+// NewTaggedCache returns a TaggedCache that passes operations through to
+// cache.
+func NewTaggedCache(cache Evicter) *TaggedCache {
+	tc := new(TaggedCache)
+	tc.cache = cache
+	tc.keys = newRadixTree()
+	tc.tags = map[string]map[string]bool{}
+	tc.keyTags = map[string][]string{}
+	cache.OnEvict(func(key string, value []byte) {
+		tc.forget(key)
+	})
+	return tc
+}
+
+// forget drops key from the key index and from every tag it carries.
+func (tc *TaggedCache) forget(key string) {
+	tc.keys.remove(key)
+	for _, tag := range tc.keyTags[key] {
+		delete(tc.tags[tag], key)
+		if len(tc.tags[tag]) == 0 {
+			delete(tc.tags, tag)
+		}
+	}
+	delete(tc.keyTags, key)
+}
+
+// MaxStorage returns the maximum number of bytes the wrapped cache can store
+func (tc *TaggedCache) MaxStorage() int {
+	return tc.cache.MaxStorage()
+}
+
+// RemainingStorage returns the number of unused bytes available in the wrapped cache
+func (tc *TaggedCache) RemainingStorage() int {
+	return tc.cache.RemainingStorage()
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (tc *TaggedCache) Get(key string) (value []byte, ok bool) {
+	return tc.cache.Get(key)
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (tc *TaggedCache) Remove(key string) (value []byte, ok bool) {
+	value, ok = tc.cache.Remove(key)
+	if ok {
+		tc.forget(key)
+	}
+	return value, ok
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+// The binding carries no tags, even if the one it replaces did.
+func (tc *TaggedCache) Set(key string, value []byte) bool {
+	return tc.SetTagged(key, value)
+}
+
+// SetTagged is Set for a binding that carries tags, replacing any tags the
+// binding it replaces carried.
+func (tc *TaggedCache) SetTagged(key string, value []byte, tags ...string) bool {
+	if !tc.cache.Set(key, value) {
+		return false
+	}
+	tc.forget(key)
+	tc.keys.insert(key)
+	for _, tag := range tags {
+		if tc.tags[tag] == nil {
+			tc.tags[tag] = map[string]bool{}
+		}
+		if !tc.tags[tag][key] {
+			tc.tags[tag][key] = true
+			tc.keyTags[key] = append(tc.keyTags[key], tag)
+		}
+	}
+	return true
+}
+
+// GetMulti gets each of keys from the wrapped cache, as a batch if it is a
+// Batcher, returning the value of each and whether it was found.
+func (tc *TaggedCache) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return batchGet(tc.cache, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index in the wrapped
+// cache, as a batch if it is a Batcher, and returns whether each binding was
+// added. The bindings carry no tags, as with Set.
+func (tc *TaggedCache) SetMulti(keys []string, values [][]byte) []bool {
+	ok := batchSet(tc.cache, keys, values)
+	for i := range ok {
+		if ok[i] {
+			tc.forget(keys[i])
+			tc.keys.insert(keys[i])
+		}
+	}
+	return ok
+}
+
+// RemoveMulti removes each of keys from the wrapped cache, as a batch if it
+// is a Batcher, returning the value of each and whether it was found.
+func (tc *TaggedCache) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	values, found = batchRemove(tc.cache, keys)
+	for i, key := range keys {
+		if found[i] {
+			tc.forget(key)
+		}
+	}
+	return values, found
+}
+
+// InvalidateTag removes every binding carrying tag, and returns how many it
+// removed.
+func (tc *TaggedCache) InvalidateTag(tag string) int {
+	keys := make([]string, 0, len(tc.tags[tag]))
+	for key := range tc.tags[tag] {
+		keys = append(keys, key)
+	}
+	return tc.removeAll(keys)
+}
+
+// RemovePrefix removes every binding whose key starts with prefix, and
+// returns how many it removed.
+func (tc *TaggedCache) RemovePrefix(prefix string) int {
+	return tc.removeAll(tc.keys.withPrefix(prefix))
+}
+
+// removeAll removes the bindings of keys, and returns how many there were.
+func (tc *TaggedCache) removeAll(keys []string) int {
+	removed := 0
+	for _, key := range keys {
+		if _, ok := tc.Remove(key); ok {
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len returns the number of bindings in the wrapped cache.
+func (tc *TaggedCache) Len() int {
+	return tc.cache.Len()
+}
+
+// Stats returns statistics about how many search hits and misses have
+// occurred in the wrapped cache.
+func (tc *TaggedCache) Stats() *Stats {
+	return tc.cache.Stats()
+}
+
+// OnEvict registers f to be called with each binding the wrapped cache evicts.
+func (tc *TaggedCache) OnEvict(f EvictFunc) {
+	tc.cache.OnEvict(f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats. ok is false if the wrapped
+// cache isn't an Inspector.
+func (tc *TaggedCache) Peek(key string) (value []byte, ok bool) {
+	return peek(tc.cache, key)
+}
+
+// Contains returns true if the given key is bound in the wrapped cache, and
+// false if it isn't an Inspector.
+func (tc *TaggedCache) Contains(key string) bool {
+	return contains(tc.cache, key)
+}
+
+// Range calls f with each binding in the wrapped cache's eviction order until
+// f returns false. f isn't called if the wrapped cache isn't an Inspector.
+func (tc *TaggedCache) Range(f func(key string, value []byte) bool) {
+	rangeBindings(tc.cache, f)
+}
+
+// Resize changes the capacity of the wrapped cache to limit bytes, if it is a
+// Resizer.
+func (tc *TaggedCache) Resize(limit int) {
+	resize(tc.cache, limit)
+}
+
+// Pin pins the binding for key in the wrapped cache so that it is never
+// evicted. Returns false if key isn't bound or the cache isn't a Pinner.
+func (tc *TaggedCache) Pin(key string) bool {
+	return pin(tc.cache, key)
+}
+
+// Unpin undoes one Pin of key in the wrapped cache. Returns false if key
+// isn't pinned or the cache isn't a Pinner.
+func (tc *TaggedCache) Unpin(key string) bool {
+	return unpin(tc.cache, key)
+}
+
+// isClosed returns true if the wrapped cache has been closed.
+func (tc *TaggedCache) isClosed() bool {
+	return isClosed(tc.cache)
+}
+
+// setError returns why the last Set on the wrapped cache failed.
+func (tc *TaggedCache) setError() error {
+	return setError(tc.cache)
+}
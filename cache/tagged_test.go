@@ -0,0 +1,152 @@
+/******************************************************************************
+ * tagged_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    A testing suite for tagged.go and radix.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// A radix tree finds the same keys with a prefix as a scan of a map, and
+// stays compressed, through random inserts and removes.
+func TestRadixTree(t *testing.T) {
+	tree := newRadixTree()
+	keys := map[string]bool{}
+	rng := rand.New(rand.NewSource(1))
+	letters := "abc"
+	randomKey := func() string {
+		key := ""
+		for i := rng.Intn(6); i > 0; i-- {
+			key += string(letters[rng.Intn(len(letters))])
+		}
+		return key
+	}
+
+	for i := 0; i < 20000; i++ {
+		key := randomKey()
+		if rng.Intn(3) == 0 {
+			tree.remove(key)
+			delete(keys, key)
+		} else {
+			tree.insert(key)
+			keys[key] = true
+		}
+
+		prefix := randomKey()
+		expected := []string{}
+		for key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				expected = append(expected, key)
+			}
+		}
+		found := tree.withPrefix(prefix)
+		sort.Strings(expected)
+		sort.Strings(found)
+		if fmt.Sprint(found) != fmt.Sprint(expected) || tree.size != len(keys) {
+			t.Errorf("After %d ops keys with prefix %q are %v, but the tree found %v", i, prefix, expected, found)
+			t.FailNow()
+		}
+		checkCompressed(t, tree.root, true)
+	}
+}
+
+// InvalidateTag removes every binding carrying the tag, and only those.
+func TestInvalidateTag(t *testing.T) {
+	tc := NewTaggedCache(NewLru(1000))
+	tc.SetTagged("user:1", []byte("a"), "users", "team:x")
+	tc.SetTagged("user:2", []byte("b"), "users")
+	tc.SetTagged("team:x", []byte("c"), "team:x")
+	tc.Set("config", []byte("d"))
+
+	if removed := tc.InvalidateTag("team:x"); removed != 2 {
+		t.Errorf("InvalidateTag(team:x) removed %d bindings, expected 2", removed)
+		t.FailNow()
+	}
+	checkKeys(t, tc, "user:2", "config")
+
+	// a binding set again without tags loses them
+	tc.Set("user:2", []byte("e"))
+	if removed := tc.InvalidateTag("users"); removed != 0 {
+		t.Errorf("InvalidateTag(users) removed %d bindings after they were set without tags", removed)
+		t.FailNow()
+	}
+	checkKeys(t, tc, "user:2", "config")
+}
+
+// RemovePrefix removes every binding whose key starts with the prefix.
+func TestRemovePrefix(t *testing.T) {
+	tc := NewTaggedCache(NewLfu(1000))
+	for _, key := range []string{"user:1", "user:10", "user:2", "users", "team:1"} {
+		tc.Set(key, []byte("value"))
+	}
+
+	if removed := tc.RemovePrefix("user:1"); removed != 2 {
+		t.Errorf("RemovePrefix(user:1) removed %d bindings, expected 2", removed)
+		t.FailNow()
+	}
+	checkKeys(t, tc, "user:2", "users", "team:1")
+	if removed := tc.RemovePrefix(""); removed != 3 || tc.Len() != 0 {
+		t.Errorf("RemovePrefix(\"\") removed %d bindings and left %d", removed, tc.Len())
+		t.FailNow()
+	}
+}
+
+// Evicted bindings leave the indexes, so their tags and prefixes don't
+// count them.
+func TestTaggedEviction(t *testing.T) {
+	tc := NewTaggedCache(NewFIFO(45))
+	for i := 0; i < 10; i++ {
+		tc.SetTagged(fmt.Sprintf("key%d", i), []byte("value"), "all")
+	}
+	if tc.Len() != 5 || len(tc.keyTags) != 5 || tc.keys.size != 5 || len(tc.tags["all"]) != 5 {
+		t.Errorf("TaggedCache indexes %d keys and tags %d of %d bindings", tc.keys.size, len(tc.keyTags), tc.Len())
+		t.FailNow()
+	}
+	if removed := tc.InvalidateTag("all"); removed != 5 || len(tc.tags) != 0 {
+		t.Errorf("InvalidateTag(all) removed %d bindings, expected 5", removed)
+		t.FailNow()
+	}
+}
+
+/******************************************************************************/
+/*                                 Helpers                                    */
+/******************************************************************************/
+
+// Fails test t if a node under n other than the root is neither a key nor
+// has more than one child.
+func checkCompressed(t *testing.T, n *radixNode, root bool) {
+	if !root && !n.leaf && len(n.children) < 2 {
+		t.Errorf("Node %q is not a key and has %d children", n.label, len(n.children))
+		t.FailNow()
+	}
+	for _, child := range n.children {
+		checkCompressed(t, child, false)
+	}
+}
+
+// Fails test t if tc doesn't hold exactly keys.
+func checkKeys(t *testing.T, tc *TaggedCache, keys ...string) {
+	for _, key := range keys {
+		if !tc.Contains(key) {
+			t.Errorf("Expected %s to be bound", key)
+			t.FailNow()
+		}
+	}
+	if tc.Len() != len(keys) {
+		t.Errorf("Expected %d bindings, found %d", len(keys), tc.Len())
+		t.FailNow()
+	}
+}
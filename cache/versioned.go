@@ -0,0 +1,192 @@
+package cache
+
+// A VersionedCache wraps a cache with memcached-style conditional stores,
+// making it a Versioner. Every binding it stores gets a new CAS token, unique
+// across all keys, that Gets returns and CompareAndSwap checks, so a
+// read-modify-write fails if the binding was stored, removed or evicted in
+// between.
+//
+// The VersionedCache tracks which keys are bound itself, so Add and Replace
+// don't count as uses of the key or change Stats. The wrapped cache must
+// only be used through the VersionedCache.
+type VersionedCache struct {
+	cache   Evicter
+	tokens  map[string]uint64
+	lastCAS uint64
+}
+
+// NewVersionedCache returns a VersionedCache that passes operations through
+// to cache.
+func NewVersionedCache(cache Evicter) *VersionedCache {
+	vc := new(VersionedCache)
+	vc.cache = cache
+	vc.tokens = map[string]uint64{}
+	cache.OnEvict(func(key string, value []byte) {
+		delete(vc.tokens, key)
+	})
+	return vc
+}
+
+// MaxStorage returns the maximum number of bytes the wrapped cache can store
+func (vc *VersionedCache) MaxStorage() int {
+	return vc.cache.MaxStorage()
+}
+
+// RemainingStorage returns the number of unused bytes available in the wrapped cache
+func (vc *VersionedCache) RemainingStorage() int {
+	return vc.cache.RemainingStorage()
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (vc *VersionedCache) Get(key string) (value []byte, ok bool) {
+	return vc.cache.Get(key)
+}
+
+// Gets is Get that also returns the CAS token of the binding, for
+// CompareAndSwap.
+func (vc *VersionedCache) Gets(key string) (value []byte, cas uint64, ok bool) {
+	value, ok = vc.cache.Get(key)
+	if !ok {
+		return nil, 0, false
+	}
+	return value, vc.tokens[key], true
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (vc *VersionedCache) Remove(key string) (value []byte, ok bool) {
+	delete(vc.tokens, key)
+	return vc.cache.Remove(key)
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+func (vc *VersionedCache) Set(key string, value []byte) bool {
+	if !vc.cache.Set(key, value) {
+		return false
+	}
+	vc.lastCAS++
+	vc.tokens[key] = vc.lastCAS
+	return true
+}
+
+// GetMulti gets each of keys from the wrapped cache, as a batch if it is a
+// Batcher, returning the value of each and whether it was found.
+func (vc *VersionedCache) GetMulti(keys []string) (values [][]byte, found []bool) {
+	return batchGet(vc.cache, keys)
+}
+
+// SetMulti sets each of keys to the value at the same index in the wrapped
+// cache, as a batch if it is a Batcher, giving each binding added a new CAS
+// token. Returns whether each binding was added.
+func (vc *VersionedCache) SetMulti(keys []string, values [][]byte) []bool {
+	ok := batchSet(vc.cache, keys, values)
+	for i := range ok {
+		if ok[i] {
+			vc.lastCAS++
+			vc.tokens[keys[i]] = vc.lastCAS
+		}
+	}
+	return ok
+}
+
+// RemoveMulti removes each of keys from the wrapped cache, as a batch if it
+// is a Batcher, returning the value of each and whether it was found.
+func (vc *VersionedCache) RemoveMulti(keys []string) (values [][]byte, found []bool) {
+	for _, key := range keys {
+		delete(vc.tokens, key)
+	}
+	return batchRemove(vc.cache, keys)
+}
+
+// Add sets key only if it isn't bound. Returns true if the binding was added.
+func (vc *VersionedCache) Add(key string, value []byte) bool {
+	if _, ok := vc.tokens[key]; ok {
+		return false
+	}
+	return vc.Set(key, value)
+}
+
+// Replace sets key only if it is already bound. Returns true if the binding
+// was replaced.
+func (vc *VersionedCache) Replace(key string, value []byte) bool {
+	if _, ok := vc.tokens[key]; !ok {
+		return false
+	}
+	return vc.Set(key, value)
+}
+
+// CompareAndSwap sets key only if it is bound and its CAS token is still cas,
+// that is, it hasn't been stored again since Gets returned cas. Returns true
+// if the binding was replaced.
+func (vc *VersionedCache) CompareAndSwap(key string, value []byte, cas uint64) bool {
+	if token, ok := vc.tokens[key]; !ok || token != cas {
+		return false
+	}
+	return vc.Set(key, value)
+}
+
+// Len returns the number of bindings in the wrapped cache.
+func (vc *VersionedCache) Len() int {
+	return vc.cache.Len()
+}
+
+// Stats returns statistics about how many search hits and misses have
+// occurred in the wrapped cache.
+func (vc *VersionedCache) Stats() *Stats {
+	return vc.cache.Stats()
+}
+
+// OnEvict registers f to be called with each binding the wrapped cache evicts.
+func (vc *VersionedCache) OnEvict(f EvictFunc) {
+	vc.cache.OnEvict(f)
+}
+
+// Peek returns the value associated with the given key, if it exists,
+// without counting as a use or changing Stats. ok is false if the wrapped
+// cache isn't an Inspector.
+func (vc *VersionedCache) Peek(key string) (value []byte, ok bool) {
+	return peek(vc.cache, key)
+}
+
+// Contains returns true if the given key is bound in the wrapped cache, and
+// false if it isn't an Inspector.
+func (vc *VersionedCache) Contains(key string) bool {
+	return contains(vc.cache, key)
+}
+
+// Range calls f with each binding in the wrapped cache's eviction order until
+// f returns false. f isn't called if the wrapped cache isn't an Inspector.
+func (vc *VersionedCache) Range(f func(key string, value []byte) bool) {
+	rangeBindings(vc.cache, f)
+}
+
+// Resize changes the capacity of the wrapped cache to limit bytes, if it is a
+// Resizer. Evicted bindings lose their CAS tokens as usual.
+func (vc *VersionedCache) Resize(limit int) {
+	resize(vc.cache, limit)
+}
+
+// Pin pins the binding for key in the wrapped cache so that it is never
+// evicted. Returns false if key isn't bound or the cache isn't a Pinner.
+func (vc *VersionedCache) Pin(key string) bool {
+	return pin(vc.cache, key)
+}
+
+// Unpin undoes one Pin of key in the wrapped cache. Returns false if key
+// isn't pinned or the cache isn't a Pinner.
+func (vc *VersionedCache) Unpin(key string) bool {
+	return unpin(vc.cache, key)
+}
+
+// isClosed returns true if the wrapped cache has been closed.
+func (vc *VersionedCache) isClosed() bool {
+	return isClosed(vc.cache)
+}
+
+// setError returns why the last Set on the wrapped cache failed.
+func (vc *VersionedCache) setError() error {
+	return setError(vc.cache)
+}
@@ -0,0 +1,122 @@
+/******************************************************************************
+ * versioned_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for versioned.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestVersionedAddReplace(t *testing.T) {
+	vc := NewVersionedCache(NewLru(64))
+
+	if vc.Replace("key", []byte("a")) {
+		t.Errorf("Replace should fail for a key that isn't bound")
+		t.FailNow()
+	}
+	if !vc.Add("key", []byte("a")) || vc.Add("key", []byte("b")) {
+		t.Errorf("Add should only succeed for a key that isn't bound")
+		t.FailNow()
+	}
+	if !vc.Replace("key", []byte("c")) {
+		t.Errorf("Replace should succeed for a bound key")
+		t.FailNow()
+	}
+	if value, _ := vc.Get("key"); !bytesEqual(value, []byte("c")) {
+		t.Errorf("Expected value c, but got %s", value)
+		t.FailNow()
+	}
+	if vc.Stats().Hits != 1 || vc.Stats().Misses != 0 {
+		t.Errorf("Add and Replace should not change stats")
+		t.FailNow()
+	}
+
+	vc.Remove("key")
+	if vc.Replace("key", []byte("d")) || !vc.Add("key", []byte("d")) {
+		t.Errorf("A removed key should be treated as unbound")
+		t.FailNow()
+	}
+}
+
+func TestVersionedCompareAndSwap(t *testing.T) {
+	vc := NewVersionedCache(NewLfu(64))
+	vc.Set("key", []byte("1"))
+	vc.Set("other", []byte("1"))
+
+	_, cas, ok := vc.Gets("key")
+	_, otherCAS, _ := vc.Gets("other")
+	if !ok || cas == otherCAS {
+		t.Errorf("Every binding should have its own CAS token")
+		t.FailNow()
+	}
+
+	if vc.CompareAndSwap("key", []byte("2"), otherCAS) {
+		t.Errorf("CompareAndSwap should fail with another key's token")
+		t.FailNow()
+	}
+	if !vc.CompareAndSwap("key", []byte("2"), cas) {
+		t.Errorf("CompareAndSwap should succeed with the current token")
+		t.FailNow()
+	}
+	if vc.CompareAndSwap("key", []byte("3"), cas) {
+		t.Errorf("CompareAndSwap should fail once the key was stored again")
+		t.FailNow()
+	}
+	if value, _ := vc.Get("key"); !bytesEqual(value, []byte("2")) {
+		t.Errorf("Expected value 2, but got %s", value)
+		t.FailNow()
+	}
+
+	vc.Remove("key")
+	vc.Set("key", []byte("4"))
+	if _, newCAS, _ := vc.Gets("key"); newCAS == cas || vc.CompareAndSwap("key", []byte("5"), cas) {
+		t.Errorf("A token from before a key was removed should not match")
+		t.FailNow()
+	}
+}
+
+func TestVersionedEviction(t *testing.T) {
+	vc := NewVersionedCache(NewFIFO(10))
+	vc.Set("a", []byte("1234"))
+	_, cas, _ := vc.Gets("a")
+	vc.Set("b", []byte("1234"))
+	vc.Set("c", []byte("1234"))
+
+	if vc.Replace("a", []byte("x")) || vc.CompareAndSwap("a", []byte("x"), cas) {
+		t.Errorf("An evicted key should be treated as unbound")
+		t.FailNow()
+	}
+	if !vc.Add("a", []byte("x")) {
+		t.Errorf("Add should succeed for an evicted key")
+		t.FailNow()
+	}
+}
+
+// Set on a bound key replaces its value, in every policy, so the wrapper's
+// Replace and CompareAndSwap store what they were given.
+func TestUpdateReplacesValue(t *testing.T) {
+	for _, policy := range experimentPolicies {
+		cache := policy.factory(64)
+		cache.Set("key", []byte("old"))
+		cache.Set("other", []byte("value"))
+		cache.Set("key", []byte("newer"))
+		if value, _ := cache.Get("key"); !bytesEqual(value, []byte("newer")) {
+			t.Errorf("%s: expected the updated value newer, but got %s", policy.name, value)
+			t.FailNow()
+		}
+		if remaining := 64 - len("keynewer") - len("othervalue"); cache.RemainingStorage() != remaining {
+			t.Errorf("%s: expected %d bytes remaining after the update, but found %d",
+				policy.name, remaining, cache.RemainingStorage())
+			t.FailNow()
+		}
+	}
+}
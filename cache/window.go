@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"time"
+)
+
+// A Window holds the hits and misses counted during one window of requests.
+type Window struct {
+	// Start is the time the window began, for windows by wall-clock
+	// interval. It is zero for windows by request count.
+	Start time.Time
+
+	// First is the number of requests counted before the window began.
+	First int
+
+	Hits   int
+	Misses int
+}
+
+// HitRate returns the fraction of the window's requests that were hits, or 0
+// if it has none.
+func (window Window) HitRate() float64 {
+	requests := window.Hits + window.Misses
+	if requests == 0 {
+		return 0
+	}
+	return float64(window.Hits) / float64(requests)
+}
+
+// hitWindows is a ring buffer of the most recent windows.
+type hitWindows struct {
+	ring     []Window
+	current  int // index of the window being counted
+	used     int // number of windows in the ring that hold counts
+	requests int // requests counted since tracking began
+
+	size     int           // requests per window, or 0 for windows by interval
+	interval time.Duration // length of each window, or 0 for windows by count
+	now      func() time.Time
+}
+
+// TrackWindows makes stats keep hit and miss counts for the last windows
+// windows of size requests each, replacing any windows kept before.
+func (stats *Stats) TrackWindows(windows int, size int) {
+	if windows < 1 || size < 1 {
+		stats.window = nil
+		return
+	}
+	stats.window = &hitWindows{
+		ring: make([]Window, windows),
+		used: 1,
+		size: size,
+	}
+}
+
+// TrackIntervals makes stats keep hit and miss counts for the last windows
+// windows of interval each, by wall-clock time, replacing any windows kept
+// before.
+func (stats *Stats) TrackIntervals(windows int, interval time.Duration) {
+	stats.trackIntervals(windows, interval, time.Now)
+}
+
+// trackIntervals is TrackIntervals with a clock, so tests don't have to sleep.
+func (stats *Stats) trackIntervals(windows int, interval time.Duration, now func() time.Time) {
+	if windows < 1 || interval <= 0 {
+		stats.window = nil
+		return
+	}
+	stats.window = &hitWindows{
+		ring:     make([]Window, windows),
+		used:     1,
+		interval: interval,
+		now:      now,
+	}
+	stats.window.ring[0].Start = now()
+}
+
+// hit counts a hit in Hits and in the current window, if windows are kept.
+func (stats *Stats) hit() {
+	stats.Hits++
+	if stats.window != nil {
+		stats.window.advance().Hits++
+	}
+}
+
+// miss counts a miss in Misses and in the current window, if windows are kept.
+func (stats *Stats) miss() {
+	stats.Misses++
+	if stats.window != nil {
+		stats.window.advance().Misses++
+	}
+}
+
+// RecentHitRate returns the hit rate over the windows kept, or the hit rate
+// since the start if Stats doesn't keep windows.
+func (stats *Stats) RecentHitRate() float64 {
+	total := Window{Hits: stats.Hits, Misses: stats.Misses}
+	if stats.window != nil {
+		total = Window{}
+		for _, window := range stats.Windows() {
+			total.Hits += window.Hits
+			total.Misses += window.Misses
+		}
+	}
+	return total.HitRate()
+}
+
+// Windows returns the windows kept, oldest first, with the current window
+// last. It returns nil if Stats doesn't keep windows.
+func (stats *Stats) Windows() []Window {
+	if stats.window == nil {
+		return nil
+	}
+	if stats.window.interval > 0 {
+		stats.window.roll()
+	}
+
+	windows := make([]Window, 0, stats.window.used)
+	n := len(stats.window.ring)
+	for i := stats.window.used - 1; i >= 0; i-- {
+		windows = append(windows, stats.window.ring[(stats.window.current-i+n)%n])
+	}
+	return windows
+}
+
+// advance starts new windows as needed for another request and returns the
+// window that counts it.
+func (w *hitWindows) advance() *Window {
+	if w.interval > 0 {
+		w.roll()
+	} else if current := w.ring[w.current]; current.Hits+current.Misses == w.size {
+		w.push(Window{First: w.requests})
+	}
+	w.requests++
+	return &w.ring[w.current]
+}
+
+// roll starts a window for every interval that has ended since the current
+// window began. Intervals with no requests leave empty windows.
+func (w *hitWindows) roll() {
+	now := w.now()
+	start := w.ring[w.current].Start
+	elapsed := int(now.Sub(start) / w.interval)
+	if elapsed < 1 {
+		return
+	}
+
+	// Windows older than the ring are dropped without being made
+	skip := elapsed - len(w.ring)
+	if skip < 0 {
+		skip = 0
+	}
+	for i := skip + 1; i <= elapsed; i++ {
+		w.push(Window{
+			Start: start.Add(time.Duration(i) * w.interval),
+			First: w.requests,
+		})
+	}
+}
+
+// clone returns a copy of w that doesn't share its ring.
+func (w *hitWindows) clone() *hitWindows {
+	c := *w
+	c.ring = append([]Window(nil), w.ring...)
+	return &c
+}
+
+// push makes window the current window, overwriting the oldest one if the
+// ring is full.
+func (w *hitWindows) push(window Window) {
+	w.current = (w.current + 1) % len(w.ring)
+	w.ring[w.current] = window
+	if w.used < len(w.ring) {
+		w.used++
+	}
+}
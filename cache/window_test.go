@@ -0,0 +1,131 @@
+/******************************************************************************
+ * window_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for window.go
+ ******************************************************************************/
+
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestWindowsUntracked(t *testing.T) {
+	lru := NewLru(64)
+	lru.Set("a", []byte("a"))
+	lru.Get("a")
+	lru.Get("b")
+
+	if lru.Stats().Windows() != nil {
+		t.Errorf("Stats should keep no windows unless asked to")
+		t.FailNow()
+	}
+	if lru.Stats().RecentHitRate() != 0.5 {
+		t.Errorf("Recent hit rate should be the hit rate since the start, but is %f", lru.Stats().RecentHitRate())
+		t.FailNow()
+	}
+}
+
+func TestWindowsByCount(t *testing.T) {
+	lru := NewLru(64)
+	lru.Stats().TrackWindows(2, 4)
+	lru.Set("a", []byte("a"))
+
+	// a phase of misses followed by a phase of hits
+	for i := 0; i < 8; i++ {
+		lru.Get("b")
+	}
+	for i := 0; i < 6; i++ {
+		lru.Get("a")
+	}
+
+	windows := lru.Stats().Windows()
+	expected := []Window{
+		{First: 8, Hits: 4},
+		{First: 12, Hits: 2},
+	}
+	checkWindows(t, windows, expected)
+
+	if lru.Stats().RecentHitRate() != 1 {
+		t.Errorf("Recent hit rate should be 1, but is %f", lru.Stats().RecentHitRate())
+		t.FailNow()
+	}
+	if lru.Stats().Hits != 6 || lru.Stats().Misses != 8 {
+		t.Errorf("Windows should not change total hits and misses")
+		t.FailNow()
+	}
+}
+
+func TestWindowsByInterval(t *testing.T) {
+	start := time.Unix(1000, 0)
+	now := start
+	clock := func() time.Time { return now }
+
+	fifo := NewFIFO(64)
+	fifo.Stats().trackIntervals(3, time.Second, clock)
+	fifo.Set("a", []byte("a"))
+
+	fifo.Get("a")
+	fifo.Get("b")
+	now = now.Add(1500 * time.Millisecond)
+	fifo.Get("a")
+
+	// a window with no requests is still kept
+	now = now.Add(2 * time.Second)
+	fifo.Get("b")
+
+	expected := []Window{
+		{Start: start.Add(time.Second), First: 2, Hits: 1},
+		{Start: start.Add(2 * time.Second), First: 3},
+		{Start: start.Add(3 * time.Second), First: 3, Misses: 1},
+	}
+	checkWindows(t, fifo.Stats().Windows(), expected)
+
+	// windows that end without requests roll over when read
+	now = now.Add(10 * time.Second)
+	for _, window := range fifo.Stats().Windows() {
+		if window.Hits+window.Misses != 0 {
+			t.Errorf("Windows should be empty after a long idle period, but are %v", fifo.Stats().Windows())
+			t.FailNow()
+		}
+	}
+	if fifo.Stats().RecentHitRate() != 0 {
+		t.Errorf("Recent hit rate should be 0 with no recent requests")
+		t.FailNow()
+	}
+}
+
+// Removing a binding isn't a request and shouldn't be counted in a window.
+func TestWindowsRemove(t *testing.T) {
+	lru := NewLru(64)
+	lru.Stats().TrackWindows(1, 10)
+	lru.Set("a", []byte("a"))
+	lru.Remove("a")
+	lru.Remove("b")
+
+	if windows := lru.Stats().Windows(); windows[0].Hits+windows[0].Misses != 0 {
+		t.Errorf("Remove should not be counted in a window, but window is %v", windows[0])
+		t.FailNow()
+	}
+}
+
+// Fails test t if windows are not the expected windows
+func checkWindows(t *testing.T, windows []Window, expected []Window) {
+	if len(windows) != len(expected) {
+		t.Errorf("Expected %d windows, but got %v", len(expected), windows)
+		t.FailNow()
+	}
+	for i := range windows {
+		if windows[i] != expected[i] {
+			t.Errorf("Expected window %d to be %v, but was %v", i, expected[i], windows[i])
+			t.FailNow()
+		}
+	}
+}
@@ -1,5 +1,8 @@
 module cos316.princeton.edu/assignment3
 
-go 1.16
+go 1.18
 
-require github.com/go-echarts/go-echarts/v2 v2.2.4 // indirect
+require (
+	github.com/go-echarts/go-echarts/v2 v2.2.4
+	github.com/klauspost/compress v1.15.15
+)
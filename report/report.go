@@ -0,0 +1,268 @@
+// Package report renders the results of cache simulations as an HTML page of
+// charts and a summary table.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// A Point is a snapshot of a simulation after Requests requests. All counts
+// are cumulative since the start of the simulation.
+type Point struct {
+	Requests  int
+	Hits      int
+	Misses    int
+	HitBytes  int
+	MissBytes int
+	Evictions int
+}
+
+// HitRate returns the fraction of lookups up to p that hit.
+func (p Point) HitRate() float64 {
+	return ratio(p.Hits, p.Hits+p.Misses)
+}
+
+// ByteHitRate returns the fraction of bytes looked up up to p that hit.
+func (p Point) ByteHitRate() float64 {
+	return ratio(p.HitBytes, p.HitBytes+p.MissBytes)
+}
+
+func ratio(a int, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// A Result is the outcome of simulating one policy at one capacity, as a
+// series of points in request order.
+type Result struct {
+	Policy   string
+	Capacity int
+	Points   []Point
+}
+
+// Final returns the last point of the result, or the zero Point if it has
+// none.
+func (r Result) Final() Point {
+	if len(r.Points) == 0 {
+		return Point{}
+	}
+	return r.Points[len(r.Points)-1]
+}
+
+// A Report is a set of results to render together.
+type Report struct {
+	Title    string
+	Subtitle string
+
+	// Window is the number of requests the windowed hit rate is computed
+	// over. Zero uses the spacing between points.
+	Window int
+
+	Results []Result
+}
+
+// Render writes the report to w as a standalone HTML page.
+func (rep *Report) Render(w io.Writer) error {
+	page := components.NewPage()
+	page.PageTitle = rep.Title
+	page.AddCharts(
+		rep.hitRateChart(),
+		rep.windowedChart(),
+		rep.missRatioChart(),
+		rep.byteHitRateChart(),
+		rep.evictionChart(),
+	)
+
+	var buf bytes.Buffer
+	if err := page.Render(&buf); err != nil {
+		return err
+	}
+
+	// Put the summary table at the top of the body
+	content := buf.Bytes()
+	at, err := bodyStart(content)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(content[:at]); err != nil {
+		return err
+	}
+	if err := rep.writeSummary(w); err != nil {
+		return err
+	}
+	_, err = w.Write(content[at:])
+	return err
+}
+
+// bodyStart returns the index in page just after its body tag.
+func bodyStart(page []byte) (int, error) {
+	body := []byte("<body>")
+	at := bytes.Index(page, body)
+	if at < 0 {
+		return 0, fmt.Errorf("report: rendered page has no %s tag", body)
+	}
+	return at + len(body), nil
+}
+
+// label names the series for a result, including the capacity only if the
+// report has more than one.
+func (rep *Report) label(r Result) string {
+	for _, other := range rep.Results {
+		if other.Capacity != r.Capacity {
+			return fmt.Sprintf("%s (%d B)", r.Policy, r.Capacity)
+		}
+	}
+	return r.Policy
+}
+
+// policies returns the distinct policy names in the order they first appear.
+func (rep *Report) policies() []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, r := range rep.Results {
+		if !seen[r.Policy] {
+			seen[r.Policy] = true
+			names = append(names, r.Policy)
+		}
+	}
+	return names
+}
+
+func (rep *Report) newLine(title string, xName string, yName string) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: title, Subtitle: rep.Subtitle}),
+		charts.WithLegendOpts(opts.Legend{Show: true, Top: "bottom"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: xName, Type: "value"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: yName, Type: "value"}),
+	)
+	return line
+}
+
+// overTime adds a series per result to line, plotting value at every point.
+func (rep *Report) overTime(line *charts.Line, value func(r Result, i int) float64) {
+	for _, r := range rep.Results {
+		data := make([]opts.LineData, len(r.Points))
+		for i, p := range r.Points {
+			data[i] = opts.LineData{Value: []interface{}{p.Requests, value(r, i)}, Symbol: "none"}
+		}
+		line.AddSeries(rep.label(r), data, charts.WithLineChartOpts(opts.LineChart{Smooth: true}))
+	}
+}
+
+func (rep *Report) hitRateChart() *charts.Line {
+	line := rep.newLine("Cumulative Hit Rate", "Requests", "Hit rate")
+	rep.overTime(line, func(r Result, i int) float64 {
+		return r.Points[i].HitRate()
+	})
+	return line
+}
+
+func (rep *Report) windowedChart() *charts.Line {
+	title := "Windowed Hit Rate"
+	if rep.Window > 0 {
+		title = fmt.Sprintf("Hit Rate over the Last %d Requests", rep.Window)
+	}
+	line := rep.newLine(title, "Requests", "Hit rate")
+	rep.overTime(line, func(r Result, i int) float64 {
+		return windowedHitRate(r.Points, i, rep.Window)
+	})
+	return line
+}
+
+// windowedHitRate returns the hit rate between points[i] and the earliest
+// point at most window requests before it, or since the start if the window
+// reaches back that far.
+func windowedHitRate(points []Point, i int, window int) float64 {
+	if i == 0 || points[i].Requests <= window {
+		return points[i].HitRate()
+	}
+	j := i - 1
+	for j > 0 && points[i].Requests-points[j-1].Requests <= window {
+		j--
+	}
+	hits := points[i].Hits - points[j].Hits
+	misses := points[i].Misses - points[j].Misses
+	return ratio(hits, hits+misses)
+}
+
+func (rep *Report) missRatioChart() *charts.Line {
+	line := rep.newLine("Miss Ratio Curve", "Capacity (bytes)", "Miss ratio")
+	for _, policy := range rep.policies() {
+		results := []Result{}
+		for _, r := range rep.Results {
+			if r.Policy == policy {
+				results = append(results, r)
+			}
+		}
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].Capacity < results[j].Capacity
+		})
+
+		data := make([]opts.LineData, len(results))
+		for i, r := range results {
+			data[i] = opts.LineData{Value: []interface{}{r.Capacity, 1 - r.Final().HitRate()}}
+		}
+		line.AddSeries(policy, data)
+	}
+	return line
+}
+
+func (rep *Report) byteHitRateChart() *charts.Line {
+	line := rep.newLine("Cumulative Byte Hit Rate", "Requests", "Byte hit rate")
+	rep.overTime(line, func(r Result, i int) float64 {
+		return r.Points[i].ByteHitRate()
+	})
+	return line
+}
+
+func (rep *Report) evictionChart() *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Evictions", Subtitle: rep.Subtitle}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true}),
+	)
+
+	names := make([]string, len(rep.Results))
+	data := make([]opts.BarData, len(rep.Results))
+	for i, r := range rep.Results {
+		names[i] = rep.label(r)
+		data[i] = opts.BarData{Value: r.Final().Evictions}
+	}
+	bar.SetXAxis(names).AddSeries("Evictions", data)
+	return bar
+}
+
+// writeSummary writes a table of the final numbers for every result.
+func (rep *Report) writeSummary(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("\n<style> table.summary {margin: 20px auto; border-collapse: collapse;} " +
+		"table.summary td, table.summary th {padding: 4px 12px; border-bottom: 1px solid #ddd; text-align: right;} " +
+		"table.summary td:first-child, table.summary th:first-child {text-align: left;} </style>\n")
+	buf.WriteString("<table class=\"summary\">\n")
+	if rep.Title != "" {
+		fmt.Fprintf(&buf, "<caption><h2>%s</h2></caption>\n", html.EscapeString(rep.Title))
+	}
+	buf.WriteString("<tr><th>Policy</th><th>Capacity</th><th>Requests</th><th>Hit rate</th>" +
+		"<th>Byte hit rate</th><th>Evictions</th></tr>\n")
+	for _, r := range rep.Results {
+		p := r.Final()
+		fmt.Fprintf(&buf, "<tr><td>%s</td><td>%d</td><td>%d</td><td>%.4f</td><td>%.4f</td><td>%d</td></tr>\n",
+			html.EscapeString(r.Policy), r.Capacity, p.Requests, p.HitRate(), p.ByteHitRate(), p.Evictions)
+	}
+	buf.WriteString("</table>\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,121 @@
+/******************************************************************************
+ * report_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for report.go
+ ******************************************************************************/
+
+package report
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+// Builds a result whose hit rate is rate for every request
+func steadyResult(policy string, capacity int, rate float64) Result {
+	r := Result{Policy: policy, Capacity: capacity}
+	for i := 1; i <= 10; i++ {
+		hits := int(rate * float64(100*i))
+		r.Points = append(r.Points, Point{
+			Requests:  100 * i,
+			Hits:      hits,
+			Misses:    100*i - hits,
+			HitBytes:  10 * hits,
+			MissBytes: 20 * (100*i - hits),
+			Evictions: i,
+		})
+	}
+	return r
+}
+
+func TestRender(t *testing.T) {
+	rep := &Report{
+		Title:  "Test <Report>",
+		Window: 200,
+		Results: []Result{
+			steadyResult("LRU", 100, 0.5),
+			steadyResult("LRU", 200, 0.7),
+			steadyResult("LFU", 100, 0.4),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := rep.Render(&buf); err != nil {
+		t.Errorf("Failed to render report: %v", err)
+		t.FailNow()
+	}
+
+	page := buf.String()
+	for _, want := range []string{
+		"Test &lt;Report&gt;",
+		"LRU (100 B)",
+		"LRU (200 B)",
+		"LFU (100 B)",
+		"Miss Ratio Curve",
+		"Hit Rate over the Last 200 Requests",
+		"<td>LFU</td><td>100</td><td>1000</td><td>0.4000</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Report should contain %q", want)
+			t.FailNow()
+		}
+	}
+	if strings.Index(page, "<table") < strings.Index(page, "<body>") {
+		t.Errorf("Summary table should be inside the body")
+		t.FailNow()
+	}
+}
+
+func TestBodyStart(t *testing.T) {
+	if at, err := bodyStart([]byte("<html><body></body>")); err != nil || at != 12 {
+		t.Errorf("Expected the body to start at 12, got %d and %v", at, err)
+		t.FailNow()
+	}
+	if _, err := bodyStart([]byte("<html></html>")); err == nil {
+		t.Errorf("Expected an error for a page without a body tag")
+		t.FailNow()
+	}
+}
+
+func TestLabel(t *testing.T) {
+	rep := &Report{Results: []Result{steadyResult("LRU", 100, 0.5), steadyResult("LFU", 100, 0.5)}}
+	if label := rep.label(rep.Results[0]); label != "LRU" {
+		t.Errorf("Label should leave out a capacity shared by all results, got %q", label)
+		t.FailNow()
+	}
+}
+
+func TestWindowedHitRate(t *testing.T) {
+	points := []Point{
+		{Requests: 100, Hits: 0, Misses: 100},
+		{Requests: 200, Hits: 0, Misses: 200},
+		{Requests: 300, Hits: 100, Misses: 200},
+		{Requests: 400, Hits: 200, Misses: 200},
+	}
+
+	for _, test := range []struct {
+		i      int
+		window int
+		want   float64
+	}{
+		{0, 0, 0},
+		{3, 0, 1},
+		{3, 200, 1},
+		{3, 300, 2.0 / 3},
+		{2, 1000, 1.0 / 3},
+	} {
+		got := windowedHitRate(points, test.i, test.window)
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Windowed hit rate at %d over %d requests is %f, expected %f", test.i, test.window, got, test.want)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Package sim replays a trace through many cache configurations at once.
+//
+// The trace is read once, in batches, and every batch is handed to a fixed
+// number of workers that each replay it through their share of the
+// configurations, so configurations run in parallel while each one still sees
+// requests in trace order and produces the same result on every run.
+package sim
+
+import (
+	"io"
+	"runtime"
+	"sync"
+
+	"cos316.princeton.edu/assignment3/cache"
+	"cos316.princeton.edu/assignment3/report"
+	"cos316.princeton.edu/assignment3/trace"
+)
+
+// A Config is one cache to simulate.
+type Config struct {
+	// Policy names the policy and any parameters it was built with, as it
+	// should appear in results.
+	Policy   string
+	Capacity int
+
+	// New returns an empty cache with a capacity of capacity bytes.
+	New func(capacity int) cache.Cache
+}
+
+// Grid returns a Config for policy at each of capacities.
+func Grid(policy string, newCache func(capacity int) cache.Cache, capacities ...int) []Config {
+	configs := make([]Config, len(capacities))
+	for i, capacity := range capacities {
+		configs[i] = Config{Policy: policy, Capacity: capacity, New: newCache}
+	}
+	return configs
+}
+
+// Progress reports how far the simulation of one Config has got.
+type Progress struct {
+	Config   int // index in the configs passed to Run
+	Policy   string
+	Capacity int
+	Point    report.Point
+	Done     bool
+}
+
+// Options controls how Run simulates.
+type Options struct {
+	// Parallelism is the number of workers, and so of goroutines, that
+	// simulate configs. Zero uses GOMAXPROCS.
+	Parallelism int
+
+	// BatchSize is the number of requests read from the trace at a time.
+	// Zero uses DefaultBatchSize.
+	BatchSize int
+
+	// SampleEvery is the number of requests between points in results.
+	// Zero records only the final point.
+	SampleEvery int
+
+	// Progress, if not nil, receives the latest point of a Config after
+	// each batch it finishes, and a final Progress with Done set. Run
+	// blocks while Progress isn't received from.
+	Progress chan<- Progress
+}
+
+// DefaultBatchSize is the number of requests read at a time if
+// Options.BatchSize is zero.
+const DefaultBatchSize = 4096
+
+// batchesAhead is how many batches the trace may be read ahead of the
+// slowest worker.
+const batchesAhead = 4
+
+// Run replays every request from r through a new cache for each config and
+// returns their results in the same order as configs. A Get that misses sets
+// the key with a value that makes its binding the request's size, as a
+// read-through cache would. Evictions are only counted for caches that are
+// a cache.Evicter.
+func Run(r trace.Reader, configs []Config, options Options) ([]report.Result, error) {
+	if options.Parallelism < 1 {
+		options.Parallelism = runtime.GOMAXPROCS(0)
+	}
+	if options.BatchSize < 1 {
+		options.BatchSize = DefaultBatchSize
+	}
+
+	workers := options.Parallelism
+	if workers > len(configs) {
+		workers = len(configs)
+	}
+
+	// Worker w simulates every config whose index is w modulo workers
+	results := make([]report.Result, len(configs))
+	batches := make([]chan []trace.Request, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		batches[w] = make(chan []trace.Request, batchesAhead)
+		simulations := []*simulation{}
+		for i := w; i < len(configs); i += workers {
+			simulations = append(simulations, newSimulation(i, configs[i], options))
+		}
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for batch := range batches[w] {
+				for _, s := range simulations {
+					s.replay(batch)
+					s.progress(false)
+				}
+			}
+			for _, s := range simulations {
+				results[s.index] = s.finish()
+			}
+		}(w)
+	}
+
+	err := readBatches(r, options.BatchSize, batches)
+	for _, ch := range batches {
+		close(ch)
+	}
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// readBatches reads r in batches of size requests and sends each batch to
+// every channel. Batches are shared and must not be changed.
+func readBatches(r trace.Reader, size int, batches []chan []trace.Request) error {
+	for {
+		batch := make([]trace.Request, 0, size)
+		var err error
+		for len(batch) < size {
+			var req trace.Request
+			req, err = r.Next()
+			if err != nil {
+				break
+			}
+			batch = append(batch, req)
+		}
+
+		if len(batch) > 0 {
+			for _, ch := range batches {
+				ch <- batch
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// A simulation is the state of one Config.
+type simulation struct {
+	index    int
+	config   Config
+	options  Options
+	cache    cache.Cache
+	result   report.Result
+	point    report.Point
+	sampled  int // requests in the last point of result
+	valueBuf []byte
+}
+
+func newSimulation(index int, config Config, options Options) *simulation {
+	s := &simulation{
+		index:   index,
+		config:  config,
+		options: options,
+		cache:   config.New(config.Capacity),
+	}
+	s.result = report.Result{Policy: config.Policy, Capacity: config.Capacity}
+	if evicter, ok := s.cache.(cache.Evicter); ok {
+		evicter.OnEvict(func(key string, value []byte) {
+			s.point.Evictions++
+		})
+	}
+	return s
+}
+
+// replay applies every request in batch to the cache.
+func (s *simulation) replay(batch []trace.Request) {
+	for _, req := range batch {
+		switch req.Op {
+		case trace.Get:
+			if _, ok := s.cache.Get(req.Key); ok {
+				s.point.Hits++
+				s.point.HitBytes += req.Size
+			} else {
+				s.point.Misses++
+				s.point.MissBytes += req.Size
+				s.cache.Set(req.Key, s.value(req))
+			}
+		case trace.Set:
+			s.cache.Set(req.Key, s.value(req))
+		case trace.Delete:
+			s.cache.Remove(req.Key)
+		}
+
+		s.point.Requests++
+		if s.options.SampleEvery > 0 && s.point.Requests%s.options.SampleEvery == 0 {
+			s.sample()
+		}
+	}
+}
+
+// value returns a value that makes the binding for req req.Size bytes. Values
+// share one buffer, which is never written to after it is allocated.
+func (s *simulation) value(req trace.Request) []byte {
+	n := req.Size - len(req.Key)
+	if n < 0 {
+		n = 0
+	}
+	if n > len(s.valueBuf) {
+		s.valueBuf = make([]byte, 2*n)
+	}
+	return s.valueBuf[:n]
+}
+
+// sample adds the current point to the result.
+func (s *simulation) sample() {
+	s.result.Points = append(s.result.Points, s.point)
+	s.sampled = s.point.Requests
+}
+
+// progress sends the current point to Options.Progress, if it is set.
+func (s *simulation) progress(done bool) {
+	if s.options.Progress == nil {
+		return
+	}
+	s.options.Progress <- Progress{
+		Config:   s.index,
+		Policy:   s.config.Policy,
+		Capacity: s.config.Capacity,
+		Point:    s.point,
+		Done:     done,
+	}
+}
+
+// finish adds the final point to the result, reports it, and returns the
+// result.
+func (s *simulation) finish() report.Result {
+	if s.sampled != s.point.Requests || len(s.result.Points) == 0 {
+		s.sample()
+	}
+	s.progress(true)
+	return s.result
+}
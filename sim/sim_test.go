@@ -0,0 +1,194 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"cos316.princeton.edu/assignment3/cache"
+	"cos316.princeton.edu/assignment3/report"
+	"cos316.princeton.edu/assignment3/trace"
+)
+
+// cdnTrace returns a trace of n Gets of keys drawn from a skewed
+// distribution, each 20 bytes, in the format read by trace.NewCDNReader.
+func cdnTrace(n int) string {
+	rng := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%d key%d 20\n", i, int(rng.ExpFloat64()*50))
+	}
+	return b.String()
+}
+
+func testConfigs() []Config {
+	configs := Grid("LRU", func(capacity int) cache.Cache { return cache.NewLru(capacity) }, 200, 400)
+	configs = append(configs, Grid("LFU", func(capacity int) cache.Cache { return cache.NewLfu(capacity) }, 200, 400)...)
+	configs = append(configs, Grid("LeCaR", func(capacity int) cache.Cache { return cache.NewLeCaR(capacity, 0.45, 0.9, 1) }, 200)...)
+	return configs
+}
+
+func TestRunMatchesSequential(t *testing.T) {
+	text := cdnTrace(10000)
+	configs := testConfigs()
+	results, err := Run(trace.NewCDNReader(strings.NewReader(text)), configs, Options{
+		Parallelism: 3,
+		BatchSize:   100,
+		SampleEvery: 1000,
+	})
+	if err != nil {
+		t.Errorf("Unexpected error running simulation: %v", err)
+		t.FailNow()
+	}
+
+	for i, config := range configs {
+		c := config.New(config.Capacity)
+		evictions := 0
+		c.(cache.Evicter).OnEvict(func(key string, value []byte) { evictions++ })
+		reader := trace.NewCDNReader(strings.NewReader(text))
+		for {
+			req, err := reader.Next()
+			if err != nil {
+				break
+			}
+			if _, ok := c.Get(req.Key); !ok {
+				c.Set(req.Key, make([]byte, req.Size-len(req.Key)))
+			}
+		}
+
+		result := results[i]
+		if result.Policy != config.Policy || result.Capacity != config.Capacity || len(result.Points) != 10 {
+			t.Errorf("Result %d is for %s at %d with %d points", i, result.Policy, result.Capacity, len(result.Points))
+			t.FailNow()
+		}
+		final := result.Final()
+		if final.Requests != 10000 || final.Hits != c.Stats().Hits || final.Misses != c.Stats().Misses || final.Evictions != evictions {
+			t.Errorf("%s at %d ended at %+v, but a sequential replay had %d hits, %d misses and %d evictions",
+				config.Policy, config.Capacity, final, c.Stats().Hits, c.Stats().Misses, evictions)
+			t.FailNow()
+		}
+		if final.HitBytes != 20*final.Hits || final.MissBytes != 20*final.Misses {
+			t.Errorf("%s at %d counted the wrong bytes: %+v", config.Policy, config.Capacity, final)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRunDeterministic(t *testing.T) {
+	text := cdnTrace(5000)
+	var first []report.Result
+	for _, parallelism := range []int{1, 2, 8} {
+		results, err := Run(trace.NewCDNReader(strings.NewReader(text)), testConfigs(), Options{
+			Parallelism: parallelism,
+			BatchSize:   64,
+			SampleEvery: 500,
+		})
+		if err != nil {
+			t.Errorf("Unexpected error running simulation: %v", err)
+			t.FailNow()
+		}
+		if first == nil {
+			first = results
+		} else if !reflect.DeepEqual(results, first) {
+			t.Errorf("Results with parallelism %d differ from parallelism 1", parallelism)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRunProgress(t *testing.T) {
+	configs := testConfigs()
+	progress := make(chan Progress)
+	done := make(chan map[int]report.Point)
+	go func() {
+		final := map[int]report.Point{}
+		for p := range progress {
+			if p.Done {
+				final[p.Config] = p.Point
+			}
+		}
+		done <- final
+	}()
+
+	results, err := Run(trace.NewCDNReader(strings.NewReader(cdnTrace(1234))), configs, Options{
+		BatchSize: 100,
+		Progress:  progress,
+	})
+	close(progress)
+	final := <-done
+	if err != nil {
+		t.Errorf("Unexpected error running simulation: %v", err)
+		t.FailNow()
+	}
+
+	if len(final) != len(configs) {
+		t.Errorf("Expected a final Progress for each of %d configs, but got %d", len(configs), len(final))
+		t.FailNow()
+	}
+	for i, result := range results {
+		if len(result.Points) != 1 || final[i] != result.Final() {
+			t.Errorf("Final Progress %+v for config %d doesn't match its result %+v", final[i], i, result.Points)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRunBoundedGoroutines(t *testing.T) {
+	configs := []Config{}
+	for i := 0; i < 20; i++ {
+		configs = append(configs, testConfigs()...)
+	}
+	baseline := runtime.NumGoroutine()
+	progress := make(chan Progress)
+	most := make(chan int)
+	go func() {
+		max := 0
+		for range progress {
+			if n := runtime.NumGoroutine(); n > max {
+				max = n
+			}
+		}
+		most <- max
+	}()
+
+	_, err := Run(trace.NewCDNReader(strings.NewReader(cdnTrace(1000))), configs, Options{
+		Parallelism: 2,
+		BatchSize:   100,
+		Progress:    progress,
+	})
+	close(progress)
+	if err != nil {
+		t.Errorf("Unexpected error running simulation: %v", err)
+		t.FailNow()
+	}
+	// the progress receiver and 2 workers
+	if max := <-most; max > baseline+3 {
+		t.Errorf("Simulating %d configs with parallelism 2 ran %d goroutines beyond the %d before",
+			len(configs), max-baseline, baseline)
+		t.FailNow()
+	}
+}
+
+type failingReader struct {
+	n int
+}
+
+func (r *failingReader) Next() (trace.Request, error) {
+	r.n++
+	if r.n > 1000 {
+		return trace.Request{}, errors.New("broken trace")
+	}
+	return trace.Request{Key: fmt.Sprint(r.n % 30), Size: 10}, nil
+}
+
+func TestRunError(t *testing.T) {
+	_, err := Run(&failingReader{}, testConfigs(), Options{BatchSize: 10})
+	if err == nil || err.Error() != "broken trace" {
+		t.Errorf("Expected the trace's error, but got %v", err)
+		t.FailNow()
+	}
+}
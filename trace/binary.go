@@ -0,0 +1,45 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// oracleGeneralRecordSize is the size of one libCacheSim oracleGeneral record:
+// uint32 clock time, uint64 object id, uint32 object size and int64 next
+// access virtual time, all little-endian.
+const oracleGeneralRecordSize = 24
+
+type oracleGeneralReader struct {
+	r      *bufio.Reader
+	record [oracleGeneralRecordSize]byte
+	count  int64
+}
+
+// NewOracleGeneralReader returns a Reader for libCacheSim's oracleGeneral
+// binary format. Keys are object ids in decimal, and the next access time
+// recorded in the trace is ignored.
+func NewOracleGeneralReader(r io.Reader) Reader {
+	return &oracleGeneralReader{r: bufio.NewReader(r)}
+}
+
+func (or *oracleGeneralReader) Next() (Request, error) {
+	_, err := io.ReadFull(or.r, or.record[:])
+	if err == io.ErrUnexpectedEOF {
+		return Request{}, fmt.Errorf("trace: record %d: truncated", or.count+1)
+	}
+	if err != nil {
+		return Request{}, err
+	}
+	or.count++
+
+	return Request{
+		Time: int64(binary.LittleEndian.Uint32(or.record[0:4])),
+		Key:  strconv.FormatUint(binary.LittleEndian.Uint64(or.record[4:12]), 10),
+		Size: int(binary.LittleEndian.Uint32(or.record[12:16])),
+		Op:   Get,
+	}, nil
+}
@@ -0,0 +1,161 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// The recorded trace format starts with recordMagic, a version byte and the
+// start time in Unix nanoseconds as a little-endian int64. Each record is then
+// the time since the previous record in nanoseconds as a uvarint, the 8-byte
+// little-endian key hash, the value size as a uvarint and a flags byte holding
+// the Op in its low two bits and the outcome in recordHit.
+var recordMagic = []byte("CTRC")
+
+const (
+	recordVersion = 1
+	recordOpMask  = 0x3
+	recordHit     = 0x4
+)
+
+// ErrBadRecording is returned when reading something that isn't a recorded
+// trace.
+var ErrBadRecording = errors.New("trace: not a recorded trace")
+
+// A Record is a request captured from a live cache together with its outcome.
+// Hit is whether a Get or Remove found the key, or whether a Set stored it.
+type Record struct {
+	Time    int64 // Unix nanoseconds
+	KeyHash uint64
+	Size    int // size of the value
+	Op      Op
+	Hit     bool
+}
+
+// A RecordWriter writes Records in the compact recorded trace format.
+type RecordWriter struct {
+	w    *bufio.Writer
+	last int64
+	buf  [binary.MaxVarintLen64*2 + 9]byte
+}
+
+// NewRecordWriter writes the header of a recorded trace starting at start,
+// in Unix nanoseconds, to w and returns a RecordWriter for its records.
+func NewRecordWriter(w io.Writer, start int64) (*RecordWriter, error) {
+	rw := &RecordWriter{w: bufio.NewWriter(w), last: start}
+	rw.w.Write(recordMagic)
+	rw.w.WriteByte(recordVersion)
+	binary.Write(rw.w, binary.LittleEndian, start)
+	return rw, rw.w.Flush()
+}
+
+// Write appends a record to the trace. Records must be written in time order.
+func (rw *RecordWriter) Write(rec Record) error {
+	delta := rec.Time - rw.last
+	if delta < 0 {
+		delta = 0
+	}
+	rw.last += delta
+
+	n := binary.PutUvarint(rw.buf[:], uint64(delta))
+	binary.LittleEndian.PutUint64(rw.buf[n:], rec.KeyHash)
+	n += 8
+	n += binary.PutUvarint(rw.buf[n:], uint64(rec.Size))
+	flags := byte(rec.Op) & recordOpMask
+	if rec.Hit {
+		flags |= recordHit
+	}
+	rw.buf[n] = flags
+	n++
+
+	_, err := rw.w.Write(rw.buf[:n])
+	return err
+}
+
+// Flush writes any buffered records to the underlying writer.
+func (rw *RecordWriter) Flush() error {
+	return rw.w.Flush()
+}
+
+// A RecordReader reads a recorded trace. As a Reader it yields requests keyed
+// by the key hash in hexadecimal.
+type RecordReader struct {
+	r     *bufio.Reader
+	last  int64
+	count int64
+}
+
+// NewRecordReader reads the header of the recorded trace in r and returns a
+// RecordReader for its records.
+func NewRecordReader(r io.Reader) (*RecordReader, error) {
+	rr := &RecordReader{r: bufio.NewReader(r)}
+
+	header := make([]byte, len(recordMagic)+1+8)
+	if _, err := io.ReadFull(rr.r, header); err != nil {
+		return nil, ErrBadRecording
+	}
+	if string(header[:len(recordMagic)]) != string(recordMagic) {
+		return nil, ErrBadRecording
+	}
+	if version := header[len(recordMagic)]; version != recordVersion {
+		return nil, fmt.Errorf("trace: unsupported recording version %d", version)
+	}
+	rr.last = int64(binary.LittleEndian.Uint64(header[len(recordMagic)+1:]))
+	return rr, nil
+}
+
+// NextRecord returns the next record in the trace, or io.EOF after the last.
+func (rr *RecordReader) NextRecord() (Record, error) {
+	delta, err := binary.ReadUvarint(rr.r)
+	if err == io.EOF {
+		return Record{}, io.EOF
+	}
+	if err != nil {
+		return Record{}, rr.truncated()
+	}
+
+	var hash [8]byte
+	if _, err := io.ReadFull(rr.r, hash[:]); err != nil {
+		return Record{}, rr.truncated()
+	}
+	size, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return Record{}, rr.truncated()
+	}
+	flags, err := rr.r.ReadByte()
+	if err != nil {
+		return Record{}, rr.truncated()
+	}
+	rr.count++
+	rr.last += int64(delta)
+
+	return Record{
+		Time:    rr.last,
+		KeyHash: binary.LittleEndian.Uint64(hash[:]),
+		Size:    int(size),
+		Op:      Op(flags & recordOpMask),
+		Hit:     flags&recordHit != 0,
+	}, nil
+}
+
+func (rr *RecordReader) truncated() error {
+	return fmt.Errorf("trace: record %d: truncated", rr.count+1)
+}
+
+// Next returns the next record in the trace as a request.
+func (rr *RecordReader) Next() (Request, error) {
+	rec, err := rr.NextRecord()
+	if err != nil {
+		return Request{}, err
+	}
+	return Request{
+		Time: rec.Time,
+		Key:  strconv.FormatUint(rec.KeyHash, 16),
+		Size: rec.Size,
+		Op:   rec.Op,
+	}, nil
+}
@@ -0,0 +1,377 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxLine is the longest line the text readers accept.
+const maxLine = 1 << 20
+
+// lineReader yields the non-blank lines of a text trace.
+type lineReader struct {
+	scanner *bufio.Scanner
+	line    int64
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	return &lineReader{scanner: scanner}
+}
+
+// next returns the next non-blank line with surrounding space trimmed.
+func (lr *lineReader) next() (string, error) {
+	for lr.scanner.Scan() {
+		lr.line++
+		line := strings.TrimSpace(lr.scanner.Text())
+		if line != "" {
+			return line, nil
+		}
+	}
+	if err := lr.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+// errorf describes a malformed line.
+func (lr *lineReader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("trace: line %d: %s", lr.line, fmt.Sprintf(format, args...))
+}
+
+// parseOp maps the operation names used by common traces to an Op.
+func parseOp(name string) (Op, bool) {
+	switch strings.ToLower(name) {
+	case "", "get", "gets", "r", "read":
+		return Get, true
+	case "set", "add", "replace", "cas", "append", "prepend", "incr", "decr", "w", "write":
+		return Set, true
+	case "delete", "del", "d":
+		return Delete, true
+	default:
+		return Get, false
+	}
+}
+
+/******************************************************************************/
+/*                               Key per line                                 */
+/******************************************************************************/
+
+type plainReader struct {
+	lines *lineReader
+}
+
+// NewPlainReader returns a Reader for a trace with one key per line. Every
+// request is a Get of unknown size, timed by its request number.
+func NewPlainReader(r io.Reader) Reader {
+	return &plainReader{newLineReader(r)}
+}
+
+func (pr *plainReader) Next() (Request, error) {
+	line, err := pr.lines.next()
+	if err != nil {
+		return Request{}, err
+	}
+	return Request{Time: pr.lines.line, Key: line, Op: Get}, nil
+}
+
+/******************************************************************************/
+/*                                    CSV                                     */
+/******************************************************************************/
+
+// CSVConfig describes the layout of a CSV trace. Columns are numbered from 0;
+// a negative column means the trace doesn't have that field, which every
+// field but Key may.
+type CSVConfig struct {
+	Comma  rune // field separator, ',' if zero
+	Header bool // whether the first record is a header to skip
+
+	Time int
+	Key  int
+	Size int
+	Op   int
+}
+
+// DefaultCSVConfig is the layout timestamp,key,size,op.
+var DefaultCSVConfig = CSVConfig{Comma: ',', Time: 0, Key: 1, Size: 2, Op: 3}
+
+type csvReader struct {
+	csv     *csv.Reader
+	config  CSVConfig
+	records int64
+	header  bool // whether the header is still to be skipped
+}
+
+// NewCSVReader returns a Reader for a CSV trace laid out as described by
+// config. Requests without a time column are timed by their request number.
+// It fails if config has no key column, since every request needs a key.
+func NewCSVReader(r io.Reader, config CSVConfig) (Reader, error) {
+	if config.Key < 0 {
+		return nil, fmt.Errorf("trace: CSV key column %d is negative", config.Key)
+	}
+	reader := csv.NewReader(r)
+	if config.Comma != 0 {
+		reader.Comma = config.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+	return &csvReader{csv: reader, config: config, header: config.Header}, nil
+}
+
+func (cr *csvReader) Next() (Request, error) {
+	if cr.header {
+		cr.header = false
+		if _, err := cr.csv.Read(); err != nil {
+			return Request{}, err
+		}
+	}
+	record, err := cr.csv.Read()
+	if err != nil {
+		return Request{}, err
+	}
+	cr.records++
+
+	field := func(column int) (string, error) {
+		if column >= len(record) {
+			return "", fmt.Errorf("trace: record %d: missing column %d", cr.records, column)
+		}
+		return record[column], nil
+	}
+
+	req := Request{Time: cr.records, Op: Get}
+	if req.Key, err = field(cr.config.Key); err != nil {
+		return Request{}, err
+	}
+	if cr.config.Time >= 0 {
+		s, err := field(cr.config.Time)
+		if err != nil {
+			return Request{}, err
+		}
+		if req.Time, err = parseTime(s); err != nil {
+			return Request{}, fmt.Errorf("trace: record %d: %v", cr.records, err)
+		}
+	}
+	if cr.config.Size >= 0 {
+		s, err := field(cr.config.Size)
+		if err != nil {
+			return Request{}, err
+		}
+		if req.Size, err = strconv.Atoi(s); err != nil {
+			return Request{}, fmt.Errorf("trace: record %d: %v", cr.records, err)
+		}
+	}
+	if cr.config.Op >= 0 {
+		s, err := field(cr.config.Op)
+		if err != nil {
+			return Request{}, err
+		}
+		op, ok := parseOp(s)
+		if !ok {
+			return Request{}, fmt.Errorf("trace: record %d: unknown operation %q", cr.records, s)
+		}
+		req.Op = op
+	}
+	return req, nil
+}
+
+// parseTime accepts integer timestamps and truncates fractional ones.
+func parseTime(s string) (int64, error) {
+	if t, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return t, nil
+	}
+	t, err := strconv.ParseFloat(s, 64)
+	return int64(t), err
+}
+
+/******************************************************************************/
+/*                                Twitter                                     */
+/******************************************************************************/
+
+type twitterReader struct {
+	csv     *csv.Reader
+	records int64
+}
+
+// NewTwitterReader returns a Reader for the Twitter cache traces, CSV records
+// of timestamp,key,key size,value size,client id,operation,TTL. Size is the
+// key size plus the value size.
+func NewTwitterReader(r io.Reader) Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 7
+	reader.ReuseRecord = true
+	return &twitterReader{csv: reader}
+}
+
+func (tr *twitterReader) Next() (Request, error) {
+	record, err := tr.csv.Read()
+	if err != nil {
+		return Request{}, err
+	}
+	tr.records++
+
+	t, err := strconv.ParseInt(record[0], 10, 64)
+	if err != nil {
+		return Request{}, fmt.Errorf("trace: record %d: %v", tr.records, err)
+	}
+	keySize, err := strconv.Atoi(record[2])
+	if err != nil {
+		return Request{}, fmt.Errorf("trace: record %d: %v", tr.records, err)
+	}
+	valueSize, err := strconv.Atoi(record[3])
+	if err != nil {
+		return Request{}, fmt.Errorf("trace: record %d: %v", tr.records, err)
+	}
+	op, ok := parseOp(record[5])
+	if !ok {
+		return Request{}, fmt.Errorf("trace: record %d: unknown operation %q", tr.records, record[5])
+	}
+	return Request{Time: t, Key: record[1], Size: keySize + valueSize, Op: op}, nil
+}
+
+/******************************************************************************/
+/*                              Wikipedia / CDN                               */
+/******************************************************************************/
+
+type cdnReader struct {
+	lines *lineReader
+}
+
+// NewCDNReader returns a Reader for Wikipedia and CDN style traces, lines of
+// "timestamp id size" separated by whitespace.
+func NewCDNReader(r io.Reader) Reader {
+	return &cdnReader{newLineReader(r)}
+}
+
+func (cr *cdnReader) Next() (Request, error) {
+	line, err := cr.lines.next()
+	if err != nil {
+		return Request{}, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return Request{}, cr.lines.errorf("expected timestamp id size, got %q", line)
+	}
+
+	t, err := parseTime(fields[0])
+	if err != nil {
+		return Request{}, cr.lines.errorf("%v", err)
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return Request{}, cr.lines.errorf("%v", err)
+	}
+	return Request{Time: t, Key: fields[1], Size: size, Op: Get}, nil
+}
+
+/******************************************************************************/
+/*                                UMass SPC                                   */
+/******************************************************************************/
+
+type spcReader struct {
+	lines *lineReader
+}
+
+// NewSPCReader returns a Reader for UMass Storage Performance Council traces,
+// lines of "ASU,LBA,size,opcode,timestamp". The key is "ASU:LBA" and the time
+// is in microseconds.
+func NewSPCReader(r io.Reader) Reader {
+	return &spcReader{newLineReader(r)}
+}
+
+func (sr *spcReader) Next() (Request, error) {
+	line, err := sr.lines.next()
+	if err != nil {
+		return Request{}, err
+	}
+	fields := strings.Split(line, ",")
+	if len(fields) < 5 {
+		return Request{}, sr.lines.errorf("expected ASU,LBA,size,opcode,timestamp, got %q", line)
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return Request{}, sr.lines.errorf("%v", err)
+	}
+	op, ok := parseOp(fields[3])
+	if !ok {
+		return Request{}, sr.lines.errorf("unknown operation %q", fields[3])
+	}
+	seconds, err := strconv.ParseFloat(fields[4], 64)
+	if err != nil {
+		return Request{}, sr.lines.errorf("%v", err)
+	}
+	return Request{
+		Time: int64(seconds * 1e6),
+		Key:  fields[0] + ":" + fields[1],
+		Size: size,
+		Op:   op,
+	}, nil
+}
+
+/******************************************************************************/
+/*                                   ARC                                      */
+/******************************************************************************/
+
+// ARCBlockSize is the size in bytes of a block in the ARC traces.
+const ARCBlockSize = 512
+
+type arcReader struct {
+	lines *lineReader
+
+	// the rest of the current line's run of blocks
+	next      int64
+	remaining int64
+	time      int64
+}
+
+// NewARCReader returns a Reader for the traces published with ARC, lines of
+// "start blocks ignored request" separated by whitespace. Each line is a run
+// of blocks and yields one Get per block, keyed by block number.
+func NewARCReader(r io.Reader) Reader {
+	return &arcReader{lines: newLineReader(r)}
+}
+
+func (ar *arcReader) Next() (Request, error) {
+	for ar.remaining == 0 {
+		line, err := ar.lines.next()
+		if err != nil {
+			return Request{}, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return Request{}, ar.lines.errorf("expected start blocks ignored request, got %q", line)
+		}
+
+		if ar.next, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+			return Request{}, ar.lines.errorf("%v", err)
+		}
+		if ar.remaining, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return Request{}, ar.lines.errorf("%v", err)
+		}
+		ar.time = ar.lines.line
+		if len(fields) >= 4 {
+			if ar.time, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+				return Request{}, ar.lines.errorf("%v", err)
+			}
+		}
+	}
+
+	req := Request{
+		Time: ar.time,
+		Key:  strconv.FormatInt(ar.next, 10),
+		Size: ARCBlockSize,
+		Op:   Get,
+	}
+	ar.next++
+	ar.remaining--
+	return req, nil
+}
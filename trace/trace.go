@@ -0,0 +1,108 @@
+// Package trace reads cache access traces in common public formats as a
+// uniform stream of requests that can be replayed against a cache.
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// An Op is the kind of cache operation a request performs.
+type Op int
+
+const (
+	// Get looks a key up, adding it on a miss
+	Get Op = iota
+	// Set writes a key
+	Set
+	// Delete removes a key
+	Delete
+)
+
+func (op Op) String() string {
+	switch op {
+	case Get:
+		return "get"
+	case Set:
+		return "set"
+	case Delete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// A Request is a single access in a trace. Time is in whatever unit the trace
+// uses, or the request number if the trace has no timestamps. Size is the
+// number of bytes the object takes up, or 0 if the trace doesn't say.
+type Request struct {
+	Time int64
+	Key  string
+	Size int
+	Op   Op
+}
+
+// A Reader streams the requests in a trace.
+type Reader interface {
+	// Next returns the next request in the trace, or io.EOF after the last.
+	Next() (Request, error)
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Decompress returns a reader that transparently decompresses r if it is
+// gzip or zstd compressed, and otherwise reads r as is.
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(magic, zstdMagic):
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(buffered), nil
+	}
+}
+
+// Open opens the named trace file, decompressing it if needed. Closing the
+// returned reader closes the file.
+func Open(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Decompress(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileReader{r, f}, nil
+}
+
+// fileReader closes both a decompressor and the file under it.
+type fileReader struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (r *fileReader) Close() error {
+	err := r.ReadCloser.Close()
+	if fileErr := r.file.Close(); err == nil {
+		err = fileErr
+	}
+	return err
+}
@@ -0,0 +1,217 @@
+/******************************************************************************
+ * trace_test.go
+ * Author:
+ * Usage:    `go test`  or  `go test -v`
+ * Description:
+ *    An unit testing suite for the trace readers
+ ******************************************************************************/
+
+package trace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/******************************************************************************/
+/*                                 Helpers                                    */
+/******************************************************************************/
+
+// Reads every request in r, failing t on any error but io.EOF
+func readAll(t *testing.T, r Reader) []Request {
+	reqs := []Request{}
+	for {
+		req, err := r.Next()
+		if err == io.EOF {
+			return reqs
+		}
+		if err != nil {
+			t.Errorf("Unexpected error reading trace: %v", err)
+			t.FailNow()
+		}
+		reqs = append(reqs, req)
+	}
+}
+
+// Fails t if got and want differ
+func checkRequests(t *testing.T, got []Request, want []Request) {
+	if len(got) != len(want) {
+		t.Errorf("Read %d requests, expected %d: %v", len(got), len(want), got)
+		t.FailNow()
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Request %d is %+v, expected %+v", i, got[i], want[i])
+			t.FailNow()
+		}
+	}
+}
+
+/******************************************************************************/
+/*                                  Tests                                     */
+/******************************************************************************/
+
+func TestPlainReader(t *testing.T) {
+	r := NewPlainReader(strings.NewReader("a\n\nb\n  c  \n"))
+	checkRequests(t, readAll(t, r), []Request{
+		{Time: 1, Key: "a"},
+		{Time: 3, Key: "b"},
+		{Time: 4, Key: "c"},
+	})
+}
+
+func TestCSVReader(t *testing.T) {
+	r, _ := NewCSVReader(strings.NewReader("1,a,10,get\n2,b,20,set\n3.5,a,10,delete\n"), DefaultCSVConfig)
+	checkRequests(t, readAll(t, r), []Request{
+		{Time: 1, Key: "a", Size: 10, Op: Get},
+		{Time: 2, Key: "b", Size: 20, Op: Set},
+		{Time: 3, Key: "a", Size: 10, Op: Delete},
+	})
+
+	// columns can be in any order, with a header and without times
+	config := CSVConfig{Comma: ';', Header: true, Time: -1, Key: 2, Size: 0, Op: -1}
+	r, _ = NewCSVReader(strings.NewReader("size;x;key\n5;_;a\n7;_;b\n"), config)
+	checkRequests(t, readAll(t, r), []Request{
+		{Time: 1, Key: "a", Size: 5},
+		{Time: 2, Key: "b", Size: 7},
+	})
+
+	r, _ = NewCSVReader(strings.NewReader("1,a,10,frobnicate\n"), DefaultCSVConfig)
+	if _, err := r.Next(); err == nil {
+		t.Errorf("Should have failed to read unknown operation")
+		t.FailNow()
+	}
+
+	// every request needs a key
+	config = CSVConfig{Time: -1, Key: -1, Size: -1, Op: -1}
+	if _, err := NewCSVReader(strings.NewReader("a\n"), config); err == nil {
+		t.Errorf("Should have failed to read a trace without a key column")
+		t.FailNow()
+	}
+}
+
+func TestTwitterReader(t *testing.T) {
+	trace := "0,key1,4,100,1,get,0\n1,key2,4,50,2,set,3600\n"
+	r := NewTwitterReader(strings.NewReader(trace))
+	checkRequests(t, readAll(t, r), []Request{
+		{Time: 0, Key: "key1", Size: 104, Op: Get},
+		{Time: 1, Key: "key2", Size: 54, Op: Set},
+	})
+}
+
+func TestCDNReader(t *testing.T) {
+	r := NewCDNReader(strings.NewReader("100 obj1 2048\n101\tobj2\t10\n"))
+	checkRequests(t, readAll(t, r), []Request{
+		{Time: 100, Key: "obj1", Size: 2048},
+		{Time: 101, Key: "obj2", Size: 10},
+	})
+
+	r = NewCDNReader(strings.NewReader("100 obj1\n"))
+	if _, err := r.Next(); err == nil {
+		t.Errorf("Should have failed to read line without a size")
+		t.FailNow()
+	}
+}
+
+func TestSPCReader(t *testing.T) {
+	r := NewSPCReader(strings.NewReader("0,20941264,8192,W,0.551706\n1,3436288,512,r,1.5\n"))
+	checkRequests(t, readAll(t, r), []Request{
+		{Time: 551706, Key: "0:20941264", Size: 8192, Op: Set},
+		{Time: 1500000, Key: "1:3436288", Size: 512, Op: Get},
+	})
+}
+
+func TestARCReader(t *testing.T) {
+	r := NewARCReader(strings.NewReader("10 3 0 1\n50 1 0 2\n"))
+	checkRequests(t, readAll(t, r), []Request{
+		{Time: 1, Key: "10", Size: ARCBlockSize},
+		{Time: 1, Key: "11", Size: ARCBlockSize},
+		{Time: 1, Key: "12", Size: ARCBlockSize},
+		{Time: 2, Key: "50", Size: ARCBlockSize},
+	})
+}
+
+func TestOracleGeneralReader(t *testing.T) {
+	var buf bytes.Buffer
+	for _, rec := range []struct {
+		time uint32
+		id   uint64
+		size uint32
+		next int64
+	}{{1, 42, 100, 3}, {2, 1 << 40, 7, -1}} {
+		binary.Write(&buf, binary.LittleEndian, rec.time)
+		binary.Write(&buf, binary.LittleEndian, rec.id)
+		binary.Write(&buf, binary.LittleEndian, rec.size)
+		binary.Write(&buf, binary.LittleEndian, rec.next)
+	}
+
+	r := NewOracleGeneralReader(bytes.NewReader(buf.Bytes()))
+	checkRequests(t, readAll(t, r), []Request{
+		{Time: 1, Key: "42", Size: 100},
+		{Time: 2, Key: "1099511627776", Size: 7},
+	})
+
+	r = NewOracleGeneralReader(bytes.NewReader(buf.Bytes()[:30]))
+	r.Next()
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("Should have failed to read truncated record, got %v", err)
+		t.FailNow()
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	trace := "a\nb\nc\n"
+	want := []Request{{Time: 1, Key: "a"}, {Time: 2, Key: "b"}, {Time: 3, Key: "c"}}
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(trace))
+	w.Close()
+
+	var zst bytes.Buffer
+	enc, _ := zstd.NewWriter(&zst)
+	enc.Write([]byte(trace))
+	enc.Close()
+
+	for _, compressed := range [][]byte{[]byte(trace), gz.Bytes(), zst.Bytes()} {
+		r, err := Decompress(bytes.NewReader(compressed))
+		if err != nil {
+			t.Errorf("Failed to decompress trace: %v", err)
+			t.FailNow()
+		}
+		checkRequests(t, readAll(t, NewPlainReader(r)), want)
+		r.Close()
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "trace.gz")
+	f, _ := os.Create(name)
+	w := gzip.NewWriter(f)
+	w.Write([]byte("1 a 10\n"))
+	w.Close()
+	f.Close()
+
+	r, err := Open(name)
+	if err != nil {
+		t.Errorf("Failed to open trace: %v", err)
+		t.FailNow()
+	}
+	defer r.Close()
+	checkRequests(t, readAll(t, NewCDNReader(r)), []Request{{Time: 1, Key: "a", Size: 10}})
+}